@@ -0,0 +1,88 @@
+package datagen
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/bigtable"
+	"github.com/r7wang/gcloud-test/timer"
+)
+
+// AccountGeneratorBigtable populates the accounts table within the ledger database.
+type AccountGeneratorBigtable struct {
+	ctx     context.Context
+	client  *bigtable.Client
+	metrics *timer.Metrics
+}
+
+// NewAccountGeneratorBigtable returns a new AccountGeneratorBigtable instance.
+func NewAccountGeneratorBigtable(
+	ctx context.Context,
+	client *bigtable.Client,
+	metrics *timer.Metrics,
+) *AccountGeneratorBigtable {
+
+	return &AccountGeneratorBigtable{
+		ctx:     ctx,
+		client:  client,
+		metrics: metrics,
+	}
+}
+
+// Generate adds an account with the initial balance for every user. Users must be generated first.
+func (gen *AccountGeneratorBigtable) Generate() error {
+	defer gen.metrics.Track(time.Now(), "AccountGenerator.Generate")
+
+	const bucketSize = 100000
+
+	userIDs, err := gen.queryIds(UserTableName)
+	if err != nil {
+		return err
+	}
+	for min := 0; min < len(userIDs); min += bucketSize {
+		max := min + bucketSize
+		if max > len(userIDs) {
+			max = len(userIDs)
+		}
+		if err := gen.generateForBucket(userIDs[min:max]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (gen *AccountGeneratorBigtable) queryIds(tableName string) ([]string, error) {
+	defer gen.metrics.Track(time.Now(), fmt.Sprintf("AccountGenerator.queryIds[%s]", tableName))
+
+	table := gen.client.Open(tableName)
+	ids := []string{}
+	err := table.ReadRows(gen.ctx, bigtable.PrefixRange(""), func(row bigtable.Row) bool {
+		ids = append(ids, row.Key())
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (gen *AccountGeneratorBigtable) generateForBucket(userIDs []string) error {
+	defer gen.metrics.Track(time.Now(), "AccountGenerator.generateForBucket")
+
+	mutations := []*bigtable.Mutation{}
+	for range userIDs {
+		mutation := bigtable.NewMutation()
+		mutation.Set(
+			DefaultColumnFamily,
+			AccountBalanceColumn,
+			bigtable.Now(),
+			[]byte(Int64String(AccountInitialBalance)))
+		mutations = append(mutations, mutation)
+	}
+	table := gen.client.Open(AccountTableName)
+	if err := mergeErrors(table.ApplyBulk(gen.ctx, userIDs, mutations)); err != nil {
+		return err
+	}
+	return nil
+}
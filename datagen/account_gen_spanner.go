@@ -0,0 +1,98 @@
+package datagen
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/r7wang/gcloud-test/timer"
+	"google.golang.org/api/iterator"
+)
+
+// AccountGeneratorSpanner populates the accounts table within the ledger database.
+type AccountGeneratorSpanner struct {
+	ctx     context.Context
+	client  *spanner.Client
+	metrics *timer.Metrics
+	schema  SpannerSchema
+}
+
+// NewAccountGeneratorSpanner returns a new AccountGeneratorSpanner instance.
+func NewAccountGeneratorSpanner(
+	ctx context.Context,
+	client *spanner.Client,
+	metrics *timer.Metrics,
+	schema SpannerSchema,
+) *AccountGeneratorSpanner {
+
+	return &AccountGeneratorSpanner{
+		ctx:     ctx,
+		client:  client,
+		metrics: metrics,
+		schema:  schema,
+	}
+}
+
+// Generate adds an account with the initial balance for every user. Users must be generated first.
+func (gen *AccountGeneratorSpanner) Generate() error {
+	defer gen.metrics.Track(time.Now(), "AccountGenerator.Generate")
+
+	const bucketSize = 5000
+
+	userIDs, err := gen.queryIds(UserTableName, gen.schema.UserIDColumn)
+	if err != nil {
+		return err
+	}
+	for min := 0; min < len(userIDs); min += bucketSize {
+		max := min + bucketSize
+		if max > len(userIDs) {
+			max = len(userIDs)
+		}
+		if err := gen.generateForBucket(userIDs[min:max]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (gen *AccountGeneratorSpanner) queryIds(tableName string, columnName string) ([]int64, error) {
+	defer gen.metrics.Track(time.Now(), fmt.Sprintf("AccountGenerator.queryIds[%s]", tableName))
+
+	stmt := spanner.Statement{
+		SQL: fmt.Sprintf(`SELECT %s FROM %s ORDER BY %s`, columnName, tableName, columnName),
+	}
+	iter := gen.client.Single().Query(gen.ctx, stmt)
+	defer iter.Stop()
+	ids := []int64{}
+	var id int64
+	for {
+		row, err := iter.Next()
+		if err != nil {
+			if err == iterator.Done {
+				break
+			}
+			return nil, err
+		}
+		if err := row.Columns(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (gen *AccountGeneratorSpanner) generateForBucket(userIDs []int64) error {
+	defer gen.metrics.Track(time.Now(), "AccountGenerator.generateForBucket")
+
+	mutations := []*spanner.Mutation{}
+	for _, userID := range userIDs {
+		mutation := spanner.InsertMap(AccountTableName, map[string]interface{}{
+			"id":      userID,
+			"balance": AccountInitialBalance,
+		})
+		mutations = append(mutations, mutation)
+	}
+	_, err := gen.client.Apply(gen.ctx, mutations)
+	return err
+}
@@ -13,6 +13,7 @@ import (
 type CompanyGeneratorBigtable struct {
 	ctx     context.Context
 	client  *bigtable.Client
+	rand    *rand.Rand
 	metrics *timer.Metrics
 }
 
@@ -21,11 +22,13 @@ func NewCompanyGeneratorBigtable(
 	ctx context.Context,
 	client *bigtable.Client,
 	metrics *timer.Metrics,
+	seed int64,
 ) *CompanyGeneratorBigtable {
 
 	return &CompanyGeneratorBigtable{
 		ctx:     ctx,
 		client:  client,
+		rand:    NewRand(seed, "CompanyGenerator"),
 		metrics: metrics,
 	}
 }
@@ -48,7 +51,7 @@ func (gen *CompanyGeneratorBigtable) Generate() error {
 			bigtable.Now(),
 			[]byte(companyName))
 		mutations = append(mutations, mutation)
-		rowKeys = append(rowKeys, Int64String(rand.Int63()))
+		rowKeys = append(rowKeys, Int64String(gen.rand.Int63()))
 	}
 	table := gen.client.Open(CompanyTableName)
 	if err := mergeErrors(table.ApplyBulk(gen.ctx, rowKeys, mutations)); err != nil {
@@ -13,6 +13,7 @@ import (
 type CompanyGeneratorSpanner struct {
 	ctx     context.Context
 	client  *spanner.Client
+	rand    *rand.Rand
 	metrics *timer.Metrics
 }
 
@@ -21,11 +22,13 @@ func NewCompanyGeneratorSpanner(
 	ctx context.Context,
 	client *spanner.Client,
 	metrics *timer.Metrics,
+	seed int64,
 ) *CompanyGeneratorSpanner {
 
 	return &CompanyGeneratorSpanner{
 		ctx:     ctx,
 		client:  client,
+		rand:    NewRand(seed, "CompanyGenerator"),
 		metrics: metrics,
 	}
 }
@@ -40,6 +43,8 @@ func NewCompanyGeneratorSpanner(
 //		inserting the commit timestamp into a column with the allow_commit_timestamp option
 //		enabled.
 //
+// The OLTP.writePath tests compare the cost of each option.
+//
 // See the links below for more information.
 //		https://cloud.google.com/spanner/docs/modify-mutation-api
 //		https://cloud.google.com/spanner/docs/commit-timestamp
@@ -52,7 +57,7 @@ func (gen *CompanyGeneratorSpanner) Generate() error {
 	mutations := []*spanner.Mutation{}
 	for _, companyName := range CompanyNames {
 		mutation := spanner.InsertMap(CompanyTableName, map[string]interface{}{
-			"id":           rand.Int63(),
+			"id":           gen.rand.Int63(),
 			"name":         companyName,
 			"creationTime": spanner.CommitTimestamp,
 		})
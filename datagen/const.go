@@ -11,6 +11,14 @@ const (
 	UserNameColumn = "Name"
 	// UserCount is the number of users to be generated.
 	UserCount = 200000
+	// AccountTableName names the table that stores the balance of each user. Every account has the
+	// same ID as the user that owns it.
+	AccountTableName = "Accounts"
+	// AccountBalanceColumn is the column name for account balances. Bigtable stores balances as
+	// decimal strings, so that they can be compared with value filters.
+	AccountBalanceColumn = "Balance"
+	// AccountInitialBalance is the balance of every account when it is generated.
+	AccountInitialBalance int64 = 1000000
 	// TransactionTableName names the table that stores transaction details.
 	TransactionTableName = "Transactions"
 	// TransactionCompanyColumn is the column name for the subject ID of a transaction.
@@ -19,6 +27,10 @@ const (
 	TransactionFromUserColumn = "FromUserId"
 	// TransactionToUserColumn is the column name for the receiver ID of a transaction.
 	TransactionToUserColumn = "ToUserId"
+	// TransactionRevisionColumn is the column name for a counter that is incremented every time a
+	// transaction is updated. In bigtable, it is the target of atomic increments. In spanner, it
+	// is never part of the primary key, so it can be updated with any schema variant.
+	TransactionRevisionColumn = "Revision"
 	// TransactionBaseID is the lowest value for a monotonically increasing transaction ID.
 	TransactionBaseID int64 = 1000000000000000000
 	// TransactionCount is the number of transactions to be generated.
@@ -30,6 +42,26 @@ const (
 	// Corresponds to 2019-09-01.
 	TransactionMaxTime int64 = 1567296000
 
+	// RegisterTableName names the table that the register tests read and write. Registers are
+	// kept apart from the generated data, so that recording a history never changes it.
+	RegisterTableName = "Registers"
+	// RegisterValueColumn is the column name for the value of a register.
+	RegisterValueColumn = "Value"
+
+	// MetadataTableName names the table that describes the database itself.
+	MetadataTableName = "Metadata"
+	// MetadataNameColumn is the column name for the name of a metadata entry. It is specific to
+	// spanner, where it is the primary key.
+	MetadataNameColumn = "Name"
+	// MetadataValueColumn is the column name for the value of a metadata entry.
+	MetadataValueColumn = "Value"
+	// MetadataSchemaName is the metadata entry that holds the name of the schema variant. It is
+	// specific to spanner.
+	MetadataSchemaName = "schema"
+	// MetadataRowKeysName is the metadata entry that holds the name of the row key strategy. It is
+	// specific to bigtable.
+	MetadataRowKeysName = "row-keys"
+
 	// DefaultColumnFamily is specific to bigtable. This name is intentionally kept short for
 	// efficiency.
 	DefaultColumnFamily = "cf"
@@ -0,0 +1,238 @@
+package datagen
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// KeyChooser picks keys out of a keyspace of n keys, identified by their index from 0 to n-1.
+// Implementations must be safe for concurrent use, with each caller passing its own random source.
+type KeyChooser interface {
+	Choose(r *rand.Rand) int64
+	// Reset restarts the sequence of a chooser that keeps state between picks. The runner resets
+	// the chooser at the start of every test, so that the keys of a test do not depend on which
+	// tests ran before it.
+	Reset()
+	// String returns the definition of the chooser, in the format accepted by ParseKeyChooser.
+	String() string
+}
+
+// ParseKeyChooser returns a KeyChooser over n keys from its definition. The following
+// definitions are supported.
+//	-	uniform: every key is equally likely.
+//	-	zipfian[:theta]: a few keys at the start of the keyspace are much more likely than the
+//		rest. Theta defaults to 0.99 and must be between 0 and 1.
+//	-	hotspot[:opFraction:keyFraction]: opFraction of the picks go to the first keyFraction of
+//		the keyspace, and the rest go to the remaining keys. Defaults to 0.9:0.1.
+//	-	latest[:theta]: zipfian, but skewed towards the end of the keyspace, where the most
+//		recently generated keys are.
+//	-	sequential: keys are picked in order, wrapping around at the end of the keyspace.
+func ParseKeyChooser(definition string, n int64) (KeyChooser, error) {
+	parts := strings.Split(definition, ":")
+	params := []float64{}
+	for _, part := range parts[1:] {
+		param, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid key distribution %s", definition)
+		}
+		params = append(params, param)
+	}
+	paramOr := func(idx int, defaultParam float64) float64 {
+		if idx < len(params) {
+			return params[idx]
+		}
+		return defaultParam
+	}
+
+	switch parts[0] {
+	case "uniform":
+		return &uniformChooser{n: n}, nil
+	case "zipfian":
+		return newZipfianChooser(n, paramOr(0, 0.99))
+	case "hotspot":
+		return newHotspotChooser(n, paramOr(0, 0.9), paramOr(1, 0.1))
+	case "latest":
+		zipf, err := newZipfianChooser(n, paramOr(0, 0.99))
+		if err != nil {
+			return nil, err
+		}
+		return &latestChooser{zipf: zipf}, nil
+	case "sequential":
+		return &sequentialChooser{n: n}, nil
+	}
+	return nil, fmt.Errorf("Unsupported key distribution %s", definition)
+}
+
+// uniformChooser picks every key with equal probability.
+type uniformChooser struct {
+	n int64
+}
+
+func (c *uniformChooser) Choose(r *rand.Rand) int64 {
+	return r.Int63() % c.n
+}
+
+func (c *uniformChooser) Reset() {}
+
+func (c *uniformChooser) String() string {
+	return "uniform"
+}
+
+// zipfianChooser picks keys following a zipfian distribution, where the key at index 0 is the most
+// popular. This follows the algorithm used by YCSB, from "Quickly Generating Billion-Record
+// Synthetic Databases" (Gray et al., SIGMOD 1994).
+//
+// Keeping the popular keys next to each other at the start of the keyspace means that they are
+// likely to be served by the same tablet or split, which is what we want to observe.
+type zipfianChooser struct {
+	n     int64
+	theta float64
+	zetan float64
+	alpha float64
+	eta   float64
+}
+
+func newZipfianChooser(n int64, theta float64) (*zipfianChooser, error) {
+	if theta <= 0 || theta >= 1 {
+		return nil, fmt.Errorf("Zipfian theta must be between 0 and 1, got %v", theta)
+	}
+	zetan := zeta(n, theta)
+	return &zipfianChooser{
+		n:     n,
+		theta: theta,
+		zetan: zetan,
+		alpha: 1 / (1 - theta),
+		eta:   (1 - math.Pow(2/float64(n), 1-theta)) / (1 - zeta(2, theta)/zetan),
+	}, nil
+}
+
+func (c *zipfianChooser) Choose(r *rand.Rand) int64 {
+	u := r.Float64()
+	uz := u * c.zetan
+	if uz < 1 {
+		return 0
+	}
+	if uz < 1+math.Pow(0.5, c.theta) {
+		return 1
+	}
+	key := int64(float64(c.n) * math.Pow(c.eta*u-c.eta+1, c.alpha))
+	if key >= c.n {
+		key = c.n - 1
+	}
+	return key
+}
+
+func (c *zipfianChooser) Reset() {}
+
+func (c *zipfianChooser) String() string {
+	return fmt.Sprintf("zipfian:%v", c.theta)
+}
+
+// zetaCache holds previously computed zeta values, since computing one takes time proportional to
+// the size of the keyspace.
+var zetaCache sync.Map
+
+// zeta returns the sum of 1/i^theta for i from 1 to n.
+func zeta(n int64, theta float64) float64 {
+	type zetaKey struct {
+		n     int64
+		theta float64
+	}
+	key := zetaKey{n: n, theta: theta}
+	if sum, ok := zetaCache.Load(key); ok {
+		return sum.(float64)
+	}
+	var sum float64
+	for i := int64(1); i <= n; i++ {
+		sum += 1 / math.Pow(float64(i), theta)
+	}
+	zetaCache.Store(key, sum)
+	return sum
+}
+
+// hotspotChooser sends a fixed fraction of the picks to a hot set at the start of the keyspace,
+// and picks uniformly within the hot and cold sets.
+type hotspotChooser struct {
+	n           int64
+	hotKeys     int64
+	opFraction  float64
+	keyFraction float64
+}
+
+func newHotspotChooser(n int64, opFraction float64, keyFraction float64) (*hotspotChooser, error) {
+	if opFraction < 0 || opFraction > 1 || keyFraction <= 0 || keyFraction >= 1 {
+		return nil, fmt.Errorf("Invalid hotspot fractions %v:%v", opFraction, keyFraction)
+	}
+	hotKeys := int64(float64(n) * keyFraction)
+	if hotKeys < 1 {
+		hotKeys = 1
+	}
+	return &hotspotChooser{
+		n:           n,
+		hotKeys:     hotKeys,
+		opFraction:  opFraction,
+		keyFraction: keyFraction,
+	}, nil
+}
+
+func (c *hotspotChooser) Choose(r *rand.Rand) int64 {
+	if r.Float64() < c.opFraction || c.hotKeys == c.n {
+		return r.Int63() % c.hotKeys
+	}
+	return c.hotKeys + r.Int63()%(c.n-c.hotKeys)
+}
+
+func (c *hotspotChooser) Reset() {}
+
+func (c *hotspotChooser) String() string {
+	return fmt.Sprintf("hotspot:%v:%v", c.opFraction, c.keyFraction)
+}
+
+// Ordered returns whether the chooser picks keys by their position in generation order, which only
+// means something if the keyspace is in generation order.
+func Ordered(keys KeyChooser) bool {
+	switch keys.(type) {
+	case *latestChooser, *sequentialChooser:
+		return true
+	}
+	return false
+}
+
+// latestChooser favors the end of the keyspace. Since transaction IDs are generated in increasing
+// order, these are the most recently generated keys.
+type latestChooser struct {
+	zipf *zipfianChooser
+}
+
+func (c *latestChooser) Choose(r *rand.Rand) int64 {
+	return c.zipf.n - 1 - c.zipf.Choose(r)
+}
+
+func (c *latestChooser) Reset() {}
+
+func (c *latestChooser) String() string {
+	return fmt.Sprintf("latest:%v", c.zipf.theta)
+}
+
+// sequentialChooser picks keys in order. Concurrent callers share the same sequence.
+type sequentialChooser struct {
+	n    int64
+	next int64
+}
+
+func (c *sequentialChooser) Choose(r *rand.Rand) int64 {
+	return (atomic.AddInt64(&c.next, 1) - 1) % c.n
+}
+
+func (c *sequentialChooser) Reset() {
+	atomic.StoreInt64(&c.next, 0)
+}
+
+func (c *sequentialChooser) String() string {
+	return "sequential"
+}
@@ -0,0 +1,179 @@
+package datagen
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestParseKeyChooser(t *testing.T) {
+	tests := []struct {
+		definition string
+		want       string
+		wantErr    bool
+	}{
+		{definition: "uniform", want: "uniform"},
+		{definition: "zipfian", want: "zipfian:0.99"},
+		{definition: "zipfian:0.5", want: "zipfian:0.5"},
+		{definition: "zipfian:0", wantErr: true},
+		{definition: "zipfian:1", wantErr: true},
+		{definition: "zipfian:-0.5", wantErr: true},
+		{definition: "zipfian:abc", wantErr: true},
+		{definition: "hotspot", want: "hotspot:0.9:0.1"},
+		{definition: "hotspot:0.8:0.2", want: "hotspot:0.8:0.2"},
+		{definition: "hotspot:0.8", want: "hotspot:0.8:0.1"},
+		{definition: "hotspot:1.5:0.1", wantErr: true},
+		{definition: "hotspot:0.9:0", wantErr: true},
+		{definition: "hotspot:0.9:1", wantErr: true},
+		{definition: "hotspot:a:b", wantErr: true},
+		{definition: "latest", want: "latest:0.99"},
+		{definition: "latest:0.7", want: "latest:0.7"},
+		{definition: "latest:2", wantErr: true},
+		{definition: "sequential", want: "sequential"},
+		{definition: "gaussian", wantErr: true},
+		{definition: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		chooser, err := ParseKeyChooser(tt.definition, 1000)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseKeyChooser(%q) = %v, want an error", tt.definition, chooser)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseKeyChooser(%q) returned error: %v", tt.definition, err)
+			continue
+		}
+		if got := chooser.String(); got != tt.want {
+			t.Errorf("ParseKeyChooser(%q).String() = %q, want %q", tt.definition, got, tt.want)
+		}
+	}
+}
+
+func TestKeyChooserRange(t *testing.T) {
+	definitions := []string{"uniform", "zipfian", "hotspot", "latest", "sequential"}
+	for _, n := range []int64{1, 2, 10, 1000} {
+		for _, definition := range definitions {
+			chooser, err := ParseKeyChooser(definition, n)
+			if err != nil {
+				t.Fatalf("ParseKeyChooser(%q, %d) returned error: %v", definition, n, err)
+			}
+			r := rand.New(rand.NewSource(1))
+			for i := 0; i < 10000; i++ {
+				if key := chooser.Choose(r); key < 0 || key >= n {
+					t.Fatalf("%s over %d keys picked %d", definition, n, key)
+				}
+			}
+		}
+	}
+}
+
+// keyFrequencies returns how often each key was picked out of the given number of picks.
+func keyFrequencies(t *testing.T, definition string, n int64, picks int) []float64 {
+	chooser, err := ParseKeyChooser(definition, n)
+	if err != nil {
+		t.Fatalf("ParseKeyChooser(%q, %d) returned error: %v", definition, n, err)
+	}
+	counts := make([]float64, n)
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < picks; i++ {
+		counts[chooser.Choose(r)]++
+	}
+	for i := range counts {
+		counts[i] /= float64(picks)
+	}
+	return counts
+}
+
+// sumFrequencies returns the total frequency of the keys in [start, end).
+func sumFrequencies(freqs []float64, start int, end int) float64 {
+	var sum float64
+	for _, freq := range freqs[start:end] {
+		sum += freq
+	}
+	return sum
+}
+
+func TestKeyChooserDistribution(t *testing.T) {
+	const n = 1000
+	const picks = 200000
+
+	tests := []struct {
+		definition string
+		start      int
+		end        int
+		min        float64
+		max        float64
+	}{
+		// Every tenth of the keyspace gets about a tenth of the picks.
+		{definition: "uniform", start: 0, end: n / 10, min: 0.09, max: 0.11},
+		{definition: "uniform", start: n - n/10, end: n, min: 0.09, max: 0.11},
+		// The hot tenth of the keyspace gets the configured fraction of the picks.
+		{definition: "hotspot", start: 0, end: n / 10, min: 0.89, max: 0.91},
+		{definition: "hotspot:0.5:0.2", start: 0, end: n / 5, min: 0.49, max: 0.51},
+		// Zipfian picks concentrate at the start of the keyspace, and latest picks at its end.
+		{definition: "zipfian", start: 0, end: n / 10, min: 0.6, max: 1},
+		{definition: "zipfian", start: 0, end: 1, min: 0.1, max: 0.2},
+		{definition: "latest", start: n - n/10, end: n, min: 0.6, max: 1},
+		{definition: "latest", start: n - 1, end: n, min: 0.1, max: 0.2},
+		// A lower theta spreads the picks more evenly.
+		{definition: "zipfian:0.2", start: 0, end: n / 10, min: 0.1, max: 0.3},
+	}
+	for _, tt := range tests {
+		freqs := keyFrequencies(t, tt.definition, n, picks)
+		if got := sumFrequencies(freqs, tt.start, tt.end); got < tt.min || got > tt.max {
+			t.Errorf("%s picked keys [%d, %d) with frequency %.3f, want between %v and %v",
+				tt.definition, tt.start, tt.end, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestZipfianChooserDecreasing(t *testing.T) {
+	freqs := keyFrequencies(t, "zipfian", 100, 200000)
+	for i := 1; i < 10; i++ {
+		if freqs[i] > freqs[i-1] {
+			t.Errorf("zipfian picked key %d with frequency %.4f, more than key %d with %.4f", i, freqs[i], i-1, freqs[i-1])
+		}
+	}
+}
+
+func TestSequentialChooser(t *testing.T) {
+	chooser, err := ParseKeyChooser("sequential", 3)
+	if err != nil {
+		t.Fatalf("ParseKeyChooser returned error: %v", err)
+	}
+	r := rand.New(rand.NewSource(1))
+	want := []int64{0, 1, 2, 0, 1}
+	for i, key := range want {
+		if got := chooser.Choose(r); got != key {
+			t.Errorf("Choose() #%d = %d, want %d", i, got, key)
+		}
+	}
+
+	// A reset restarts the sequence, so that every test sees the same keys.
+	chooser.Reset()
+	for i, key := range want {
+		if got := chooser.Choose(r); got != key {
+			t.Errorf("Choose() #%d after Reset() = %d, want %d", i, got, key)
+		}
+	}
+}
+
+func TestOrdered(t *testing.T) {
+	tests := map[string]bool{
+		"uniform":    false,
+		"zipfian":    false,
+		"hotspot":    false,
+		"latest":     true,
+		"sequential": true,
+	}
+	for definition, want := range tests {
+		chooser, err := ParseKeyChooser(definition, 1000)
+		if err != nil {
+			t.Fatalf("ParseKeyChooser(%q) returned error: %v", definition, err)
+		}
+		if got := Ordered(chooser); got != want {
+			t.Errorf("Ordered(%q) = %v, want %v", definition, got, want)
+		}
+	}
+}
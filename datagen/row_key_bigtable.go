@@ -0,0 +1,271 @@
+package datagen
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigtable"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultRowKeyStrategy names the row key strategy that is used unless another one is chosen.
+const DefaultRowKeyStrategy = "sequential"
+
+// rowKeySaltBuckets is the number of prefixes that the salted strategy spreads row keys over.
+const rowKeySaltBuckets = 16
+
+// TransactionKey holds the fields of a transaction that a row key can be built from.
+type TransactionKey struct {
+	ID         int64
+	CompanyID  string
+	FromUserID string
+	Time       time.Time
+}
+
+// RowKeyStrategy encodes and decodes the row keys of the Bigtable transactions table. Row key
+// design decides both how writes are spread across nodes and which reads can be served by a
+// single range scan, so each strategy favours a different set of workflows.
+//
+// See the link below for more information:
+//		https://cloud.google.com/bigtable/docs/schema-design#row-keys
+type RowKeyStrategy interface {
+	// Name returns the name of the strategy, in the format accepted by ParseRowKeyStrategy.
+	Name() string
+	// Encode returns the row key of a transaction.
+	Encode(key TransactionKey) string
+	// Decode returns the fields of a transaction that are part of its row key. Every row key
+	// includes the transaction ID.
+	Decode(rowKey string) (TransactionKey, error)
+	// Addressable returns whether the row key can be built from the transaction ID alone.
+	Addressable() bool
+	// IDRange returns the rows of every transaction with an ID from startID (inclusive) to endID
+	// (exclusive), if the row keys can be built from IDs alone.
+	IDRange(startID int64, endID int64) (bigtable.RowSet, bool)
+	// UserPrefix returns the prefix shared by the row keys of every transaction sent by a user,
+	// if the strategy groups transactions by their sender.
+	UserPrefix(userID string) (string, bool)
+}
+
+// ParseRowKeyStrategy returns the named RowKeyStrategy. The following strategies are supported.
+//	-	sequential: the transaction ID. Generated IDs are sequential, so bulk writes all land on
+//		the same node.
+//	-	salted: the transaction ID, prefixed by one of a few buckets picked by hashing the ID.
+//		Sequential writes are spread over the buckets, and the key can still be built from the
+//		ID.
+//	-	user-reversed-time: the sender, followed by the time in reverse order and the ID. Every
+//		transaction of a user can be read with a single scan, most recent first.
+//	-	field-promotion: the company and the sender, followed by the ID. The fields that reads
+//		filter on are promoted from columns into the key.
+func ParseRowKeyStrategy(name string) (RowKeyStrategy, error) {
+	switch name {
+	case "sequential":
+		return sequentialRowKeys{}, nil
+	case "salted":
+		return saltedRowKeys{}, nil
+	case "user-reversed-time":
+		return userReversedTimeRowKeys{}, nil
+	case "field-promotion":
+		return fieldPromotionRowKeys{}, nil
+	}
+	return nil, fmt.Errorf("Unsupported row key strategy %s, expected one of %s", name, strings.Join(RowKeyStrategyNames(), ", "))
+}
+
+// RowKeyStrategyNames returns the name of every row key strategy, in sorted order.
+func RowKeyStrategyNames() []string {
+	names := []string{"sequential", "salted", "user-reversed-time", "field-promotion"}
+	sort.Strings(names)
+	return names
+}
+
+// WriteRowKeyStrategy stores the name of the row key strategy that the transactions were
+// generated with.
+func WriteRowKeyStrategy(ctx context.Context, client *bigtable.Client, name string) error {
+	mutation := bigtable.NewMutation()
+	mutation.Set(DefaultColumnFamily, MetadataValueColumn, bigtable.Now(), []byte(name))
+	table := client.Open(MetadataTableName)
+	return table.Apply(ctx, MetadataRowKeysName, mutation)
+}
+
+// ReadRowKeyStrategy returns the name of the row key strategy that the transactions were
+// generated with. It fails with a NotFound error if the instance does not record its strategy.
+func ReadRowKeyStrategy(ctx context.Context, client *bigtable.Client) (string, error) {
+	table := client.Open(MetadataTableName)
+	row, err := table.ReadRow(ctx, MetadataRowKeysName, bigtable.RowFilter(bigtable.LatestNFilter(1)))
+	if err != nil {
+		return "", err
+	}
+	cells := row[DefaultColumnFamily]
+	if len(cells) == 0 {
+		return "", status.Errorf(codes.NotFound, "no %s entry found in %s", MetadataRowKeysName, MetadataTableName)
+	}
+	return string(cells[0].Value), nil
+}
+
+// splitRowKey splits a row key into the given number of parts.
+func splitRowKey(rowKey string, numParts int) ([]string, error) {
+	parts := strings.Split(rowKey, "#")
+	if len(parts) != numParts {
+		return nil, fmt.Errorf("Invalid row key %s", rowKey)
+	}
+	return parts, nil
+}
+
+type sequentialRowKeys struct{}
+
+func (sequentialRowKeys) Name() string {
+	return "sequential"
+}
+
+func (sequentialRowKeys) Encode(key TransactionKey) string {
+	return Int64String(key.ID)
+}
+
+func (sequentialRowKeys) Decode(rowKey string) (TransactionKey, error) {
+	id, err := strconv.ParseInt(rowKey, 10, 64)
+	if err != nil {
+		return TransactionKey{}, fmt.Errorf("Invalid row key %s", rowKey)
+	}
+	return TransactionKey{ID: id}, nil
+}
+
+func (sequentialRowKeys) Addressable() bool {
+	return true
+}
+
+func (k sequentialRowKeys) IDRange(startID int64, endID int64) (bigtable.RowSet, bool) {
+	return bigtable.NewRange(k.Encode(TransactionKey{ID: startID}), k.Encode(TransactionKey{ID: endID})), true
+}
+
+func (sequentialRowKeys) UserPrefix(userID string) (string, bool) {
+	return "", false
+}
+
+type saltedRowKeys struct{}
+
+func (saltedRowKeys) Name() string {
+	return "salted"
+}
+
+func (saltedRowKeys) Encode(key TransactionKey) string {
+	h := fnv.New64a()
+	h.Write(int64Bytes(key.ID))
+	return saltedRowKey(h.Sum64()%rowKeySaltBuckets, key.ID)
+}
+
+func (saltedRowKeys) Decode(rowKey string) (TransactionKey, error) {
+	parts, err := splitRowKey(rowKey, 2)
+	if err != nil {
+		return TransactionKey{}, err
+	}
+	id, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return TransactionKey{}, fmt.Errorf("Invalid row key %s", rowKey)
+	}
+	return TransactionKey{ID: id}, nil
+}
+
+func (saltedRowKeys) Addressable() bool {
+	return true
+}
+
+// IDRange reads the same ID range from every bucket, since consecutive IDs are spread over all of
+// them. Generated IDs all have the same number of digits, so they sort numerically within a bucket.
+func (saltedRowKeys) IDRange(startID int64, endID int64) (bigtable.RowSet, bool) {
+	ranges := bigtable.RowRangeList{}
+	for bucket := uint64(0); bucket < rowKeySaltBuckets; bucket++ {
+		ranges = append(ranges, bigtable.NewRange(saltedRowKey(bucket, startID), saltedRowKey(bucket, endID)))
+	}
+	return ranges, true
+}
+
+func saltedRowKey(bucket uint64, id int64) string {
+	return fmt.Sprintf("%02d#%d", bucket, id)
+}
+
+func (saltedRowKeys) UserPrefix(userID string) (string, bool) {
+	return "", false
+}
+
+type userReversedTimeRowKeys struct{}
+
+func (userReversedTimeRowKeys) Name() string {
+	return "user-reversed-time"
+}
+
+// Encode pads the reversed time to a fixed width, so that keys sort by time within each user.
+func (userReversedTimeRowKeys) Encode(key TransactionKey) string {
+	return fmt.Sprintf("%s#%019d#%d", key.FromUserID, math.MaxInt64-key.Time.UnixNano(), key.ID)
+}
+
+func (userReversedTimeRowKeys) Decode(rowKey string) (TransactionKey, error) {
+	parts, err := splitRowKey(rowKey, 3)
+	if err != nil {
+		return TransactionKey{}, err
+	}
+	reversed, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return TransactionKey{}, fmt.Errorf("Invalid row key %s", rowKey)
+	}
+	id, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return TransactionKey{}, fmt.Errorf("Invalid row key %s", rowKey)
+	}
+	return TransactionKey{
+		ID:         id,
+		FromUserID: parts[0],
+		Time:       time.Unix(0, math.MaxInt64-reversed),
+	}, nil
+}
+
+func (userReversedTimeRowKeys) Addressable() bool {
+	return false
+}
+
+func (userReversedTimeRowKeys) IDRange(startID int64, endID int64) (bigtable.RowSet, bool) {
+	return nil, false
+}
+
+func (userReversedTimeRowKeys) UserPrefix(userID string) (string, bool) {
+	return userID + "#", true
+}
+
+type fieldPromotionRowKeys struct{}
+
+func (fieldPromotionRowKeys) Name() string {
+	return "field-promotion"
+}
+
+func (fieldPromotionRowKeys) Encode(key TransactionKey) string {
+	return fmt.Sprintf("%s#%s#%d", key.CompanyID, key.FromUserID, key.ID)
+}
+
+func (fieldPromotionRowKeys) Decode(rowKey string) (TransactionKey, error) {
+	parts, err := splitRowKey(rowKey, 3)
+	if err != nil {
+		return TransactionKey{}, err
+	}
+	id, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return TransactionKey{}, fmt.Errorf("Invalid row key %s", rowKey)
+	}
+	return TransactionKey{ID: id, CompanyID: parts[0], FromUserID: parts[1]}, nil
+}
+
+func (fieldPromotionRowKeys) Addressable() bool {
+	return false
+}
+
+func (fieldPromotionRowKeys) IDRange(startID int64, endID int64) (bigtable.RowSet, bool) {
+	return nil, false
+}
+
+func (fieldPromotionRowKeys) UserPrefix(userID string) (string, bool) {
+	return "", false
+}
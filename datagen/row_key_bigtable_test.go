@@ -0,0 +1,101 @@
+package datagen
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigtable"
+)
+
+func TestRowKeyStrategyRoundTrip(t *testing.T) {
+	key := TransactionKey{
+		ID:         TransactionBaseID + 42,
+		CompanyID:  "7",
+		FromUserID: "11",
+		Time:       time.Unix(TransactionMinTime, 0),
+	}
+	tests := []struct {
+		name string
+		want TransactionKey
+	}{
+		{name: "sequential", want: TransactionKey{ID: key.ID}},
+		{name: "salted", want: TransactionKey{ID: key.ID}},
+		{name: "user-reversed-time", want: TransactionKey{ID: key.ID, FromUserID: key.FromUserID, Time: key.Time}},
+		{name: "field-promotion", want: TransactionKey{ID: key.ID, CompanyID: key.CompanyID, FromUserID: key.FromUserID}},
+	}
+	for _, tt := range tests {
+		strategy, err := ParseRowKeyStrategy(tt.name)
+		if err != nil {
+			t.Fatalf("ParseRowKeyStrategy(%q) returned error: %v", tt.name, err)
+		}
+		if got := strategy.Name(); got != tt.name {
+			t.Errorf("ParseRowKeyStrategy(%q).Name() = %q", tt.name, got)
+		}
+		rowKey := strategy.Encode(key)
+		got, err := strategy.Decode(rowKey)
+		if err != nil {
+			t.Fatalf("%s: Decode(%q) returned error: %v", tt.name, rowKey, err)
+		}
+		if got.ID != tt.want.ID || got.CompanyID != tt.want.CompanyID ||
+			got.FromUserID != tt.want.FromUserID || !got.Time.Equal(tt.want.Time) {
+			t.Errorf("%s: Decode(Encode(%+v)) = %+v, want %+v", tt.name, key, got, tt.want)
+		}
+	}
+}
+
+func TestRowKeyStrategyDecodeInvalid(t *testing.T) {
+	for _, name := range RowKeyStrategyNames() {
+		strategy, err := ParseRowKeyStrategy(name)
+		if err != nil {
+			t.Fatalf("ParseRowKeyStrategy(%q) returned error: %v", name, err)
+		}
+		if got, err := strategy.Decode("a#b"); err == nil {
+			t.Errorf("%s: Decode(%q) = %+v, want an error", name, "a#b", got)
+		}
+	}
+}
+
+func TestParseRowKeyStrategyUnknown(t *testing.T) {
+	if strategy, err := ParseRowKeyStrategy("unknown"); err == nil {
+		t.Errorf("ParseRowKeyStrategy(%q) = %v, want an error", "unknown", strategy)
+	}
+}
+
+func TestRowKeyStrategyIDRange(t *testing.T) {
+	const startID, endID = TransactionBaseID + 10, TransactionBaseID + 20
+	for _, name := range RowKeyStrategyNames() {
+		strategy, err := ParseRowKeyStrategy(name)
+		if err != nil {
+			t.Fatalf("ParseRowKeyStrategy(%q) returned error: %v", name, err)
+		}
+		rows, ok := strategy.IDRange(startID, endID)
+		if ok != strategy.Addressable() {
+			t.Errorf("%s: IDRange returned %v, want %v", name, ok, strategy.Addressable())
+		}
+		if !ok {
+			continue
+		}
+		for id := TransactionBaseID; id < TransactionBaseID+30; id++ {
+			rowKey := strategy.Encode(TransactionKey{ID: id})
+			want := id >= startID && id < endID
+			if got := containsRow(rows, rowKey); got != want {
+				t.Errorf("%s: IDRange(%d, %d) contains %q = %v, want %v", name, startID, endID, rowKey, got, want)
+			}
+		}
+	}
+}
+
+// containsRow returns whether a single range or a list of ranges contains the row key.
+func containsRow(rows bigtable.RowSet, rowKey string) bool {
+	switch rows := rows.(type) {
+	case bigtable.RowRange:
+		return rows.Contains(rowKey)
+	case bigtable.RowRangeList:
+		for _, r := range rows {
+			if r.Contains(rowKey) {
+				return true
+			}
+		}
+	}
+	return false
+}
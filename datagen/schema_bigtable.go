@@ -23,7 +23,10 @@ func (s *SchemaBigtable) CreateTables() error {
 	tableNames := []string{
 		CompanyTableName,
 		UserTableName,
+		AccountTableName,
 		TransactionTableName,
+		RegisterTableName,
+		MetadataTableName,
 	}
 	for _, tableName := range tableNames {
 		if err := s.client.CreateTable(s.ctx, tableName); err != nil {
@@ -6,7 +6,11 @@ import (
 	"io"
 	"os"
 	"regexp"
+	"sort"
+	"strings"
+	"time"
 
+	"cloud.google.com/go/spanner"
 	database "cloud.google.com/go/spanner/admin/database/apiv1"
 	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
 )
@@ -24,7 +28,102 @@ func NewSchemaSpanner(ctx context.Context, client *database.DatabaseAdminClient)
 	return &SchemaSpanner{ctx: ctx, client: client, w: os.Stdout}
 }
 
-// CreateDatabase initializes the ledger database.
+// DefaultSpannerSchema names the schema variant that is used unless another one is chosen.
+const DefaultSpannerSchema = "key-from-user-and-time"
+
+// SpannerSchema describes a schema variant. Variants differ in key design, which is the main
+// subject of the Spanner experiments.
+type SpannerSchema struct {
+	Name string
+	// UserIDColumn names the primary key column of the users table. An interleaved table must
+	// start its primary key with the key columns of its parent, under the same names, so the
+	// column is named after the sender of a transaction when transactions are interleaved.
+	UserIDColumn string
+	// Interleaved is whether transactions are interleaved in the user that sent them, in which
+	// case deleting a user also deletes their transactions.
+	Interleaved bool
+	// TransactionKeyColumns lists the primary key columns of the transactions table, in key order.
+	TransactionKeyColumns []string
+	// TransactionIDIndex names the unique index on transaction IDs, if the ID alone is not the
+	// primary key. The index stores every other column, so that reads by ID never need to join
+	// back to the table.
+	TransactionIDIndex string
+	statements         func() []string
+}
+
+// TransactionKey returns the primary key of a transaction, built from whichever of its fields are
+// key columns in this schema variant.
+func (s SpannerSchema) TransactionKey(id int64, fromUserID int64, t time.Time) spanner.Key {
+	key := spanner.Key{}
+	for _, column := range s.TransactionKeyColumns {
+		switch column {
+		case "Id":
+			key = append(key, id)
+		case TransactionFromUserColumn:
+			key = append(key, fromUserID)
+		case "Time":
+			key = append(key, t)
+		}
+	}
+	return key
+}
+
+// TransactionIDHint returns the table hint that makes a query look up transactions by ID through
+// the ID index, or nothing if the ID is the primary key.
+func (s SpannerSchema) TransactionIDHint() string {
+	if s.TransactionIDIndex == "" {
+		return ""
+	}
+	return fmt.Sprintf("@{FORCE_INDEX=%s}", s.TransactionIDIndex)
+}
+
+// spannerSchemas maps the name of each schema variant to its description.
+var spannerSchemas = map[string]SpannerSchema{
+	"key-id": {
+		Name:                  "key-id",
+		UserIDColumn:          "Id",
+		TransactionKeyColumns: []string{"Id"},
+		statements:            schemaDefault,
+	},
+	"key-from-user-and-time": {
+		Name:                  "key-from-user-and-time",
+		UserIDColumn:          "Id",
+		TransactionKeyColumns: []string{TransactionFromUserColumn, "Time"},
+		TransactionIDIndex:    "UniqueId",
+		statements:            schemaKeyFromUserAndTime,
+	},
+	"interleaved": {
+		Name:                  "interleaved",
+		UserIDColumn:          TransactionFromUserColumn,
+		Interleaved:           true,
+		TransactionKeyColumns: []string{TransactionFromUserColumn, "Time", "Id"},
+		TransactionIDIndex:    "UniqueId",
+		statements:            schemaInterleaved,
+	},
+}
+
+// LookupSpannerSchema returns the named schema variant.
+func LookupSpannerSchema(name string) (SpannerSchema, error) {
+	schema, ok := spannerSchemas[name]
+	if !ok {
+		return SpannerSchema{}, fmt.Errorf("Unknown schema %s, expected one of %s", name, strings.Join(SpannerSchemaNames(), ", "))
+	}
+	return schema, nil
+}
+
+// SpannerSchemaNames returns the name of every schema variant, in sorted order.
+func SpannerSchemaNames() []string {
+	names := []string{}
+	for name := range spannerSchemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CreateDatabase initializes the ledger database with the given schema variant. The name of the
+// variant is also stored in the database, so that the tests can report which variant they ran
+// against.
 //
 // Primary keys are randomly generated because there are no combinations of attributes that are
 // sufficient for defining uniqueness. Attempting to define uniqueness through any set of
@@ -39,7 +138,7 @@ func NewSchemaSpanner(ctx context.Context, client *database.DatabaseAdminClient)
 //	-	In either case, we may want to consider writing a retry in case of collision for tables
 //		that we anticipate to have more records than a certain threshold. This is just a
 //		preventative measure to ensure correctness.
-func (s *SchemaSpanner) CreateDatabase(db string) error {
+func (s *SchemaSpanner) CreateDatabase(db string, schema SpannerSchema) error {
 	matches := regexp.MustCompile("^(.*)/databases/(.*)$").FindStringSubmatch(db)
 	if matches == nil || len(matches) != 3 {
 		return fmt.Errorf("Invalid database id %s", db)
@@ -47,7 +146,7 @@ func (s *SchemaSpanner) CreateDatabase(db string) error {
 	op, err := s.client.CreateDatabase(s.ctx, &adminpb.CreateDatabaseRequest{
 		Parent:          matches[1],
 		CreateStatement: "CREATE DATABASE `" + matches[2] + "`",
-		ExtraStatements: schemaKeyFromUserAndTime(),
+		ExtraStatements: append(append(schema.statements(), schemaRegisters()...), schemaMetadata()...),
 	})
 	if err != nil {
 		return err
@@ -58,6 +157,52 @@ func (s *SchemaSpanner) CreateDatabase(db string) error {
 	return nil
 }
 
+// WriteSchemaName stores the name of the schema variant that the database was created with.
+func WriteSchemaName(ctx context.Context, client *spanner.Client, schemaName string) error {
+	mutation := spanner.InsertOrUpdate(
+		MetadataTableName,
+		[]string{MetadataNameColumn, MetadataValueColumn},
+		[]interface{}{MetadataSchemaName, schemaName})
+	_, err := client.Apply(ctx, []*spanner.Mutation{mutation})
+	return err
+}
+
+// ReadSchemaName returns the name of the schema variant that the database was created with. It
+// fails with a NotFound error if the database does not record its schema variant.
+func ReadSchemaName(ctx context.Context, client *spanner.Client) (string, error) {
+	row, err := client.Single().ReadRow(
+		ctx,
+		MetadataTableName,
+		spanner.Key{MetadataSchemaName},
+		[]string{MetadataValueColumn})
+	if err != nil {
+		return "", err
+	}
+	var schemaName string
+	err = row.Columns(&schemaName)
+	return schemaName, err
+}
+
+// schemaMetadata describes the database itself, independent of the schema variant.
+func schemaMetadata() []string {
+	return []string{
+		`CREATE TABLE Metadata(
+			Name STRING(1024) NOT NULL,
+			Value STRING(MAX) NOT NULL
+		) PRIMARY KEY(Name)`,
+	}
+}
+
+// schemaRegisters holds the registers of the register tests, independent of the schema variant.
+func schemaRegisters() []string {
+	return []string{
+		`CREATE TABLE Registers(
+			Id INT64 NOT NULL,
+			Value STRING(MAX) NOT NULL
+		) PRIMARY KEY(Id)`,
+	}
+}
+
 func schemaDefault() []string {
 	return []string{
 		`CREATE TABLE Users(
@@ -72,13 +217,18 @@ func schemaDefault() []string {
 			CreationTime TIMESTAMP NOT NULL
 			OPTIONS(allow_commit_timestamp=true)
 		) PRIMARY KEY(Id)`,
+		`CREATE TABLE Accounts(
+			Id INT64 NOT NULL,
+			Balance INT64 NOT NULL
+		) PRIMARY KEY(Id)`,
 		`CREATE TABLE Transactions(
 			Id INT64 NOT NULL,
 			CompanyId INT64 NOT NULL,
 			FromUserId INT64 NOT NULL,
 			ToUserId INT64 NOT NULL,
 			Time TIMESTAMP NOT NULL
-			OPTIONS(allow_commit_timestamp=true)
+			OPTIONS(allow_commit_timestamp=true),
+			Revision INT64
 		) PRIMARY KEY(Id)`,
 	}
 }
@@ -97,14 +247,56 @@ func schemaKeyFromUserAndTime() []string {
 			CreationTime TIMESTAMP NOT NULL
 			OPTIONS(allow_commit_timestamp=true)
 		) PRIMARY KEY(Id)`,
+		`CREATE TABLE Accounts(
+			Id INT64 NOT NULL,
+			Balance INT64 NOT NULL
+		) PRIMARY KEY(Id)`,
 		`CREATE TABLE Transactions(
 			Id INT64 NOT NULL,
 			CompanyId INT64 NOT NULL,
 			FromUserId INT64 NOT NULL,
 			ToUserId INT64 NOT NULL,
 			Time TIMESTAMP NOT NULL
-			OPTIONS(allow_commit_timestamp=true)
+			OPTIONS(allow_commit_timestamp=true),
+			Revision INT64
 		) PRIMARY KEY(FromUserId, Time)`,
-		`CREATE UNIQUE INDEX UniqueId ON Transactions(Id)`,
+		`CREATE UNIQUE INDEX UniqueId ON Transactions(Id) STORING (CompanyId, ToUserId, Revision)`,
+	}
+}
+
+// schemaInterleaved stores the transactions of each user under the user row that sent them, so
+// that a user and their transactions are read together and deleted together.
+//
+// See the link below for more information:
+//		https://cloud.google.com/spanner/docs/schema-and-data-model#creating-interleaved-tables
+func schemaInterleaved() []string {
+	return []string{
+		`CREATE TABLE Users(
+			FromUserId INT64 NOT NULL,
+			Name STRING(2048) NOT NULL,
+			CreationTime TIMESTAMP NOT NULL
+			OPTIONS(allow_commit_timestamp=true)
+		) PRIMARY KEY(FromUserId)`,
+		`CREATE TABLE Companies(
+			Id INT64 NOT NULL,
+			Name STRING(2048) NOT NULL,
+			CreationTime TIMESTAMP NOT NULL
+			OPTIONS(allow_commit_timestamp=true)
+		) PRIMARY KEY(Id)`,
+		`CREATE TABLE Accounts(
+			Id INT64 NOT NULL,
+			Balance INT64 NOT NULL
+		) PRIMARY KEY(Id)`,
+		`CREATE TABLE Transactions(
+			FromUserId INT64 NOT NULL,
+			Time TIMESTAMP NOT NULL
+			OPTIONS(allow_commit_timestamp=true),
+			Id INT64 NOT NULL,
+			CompanyId INT64 NOT NULL,
+			ToUserId INT64 NOT NULL,
+			Revision INT64
+		) PRIMARY KEY(FromUserId, Time, Id),
+		INTERLEAVE IN PARENT Users ON DELETE CASCADE`,
+		`CREATE UNIQUE INDEX UniqueId ON Transactions(Id) STORING (CompanyId, ToUserId, Revision)`,
 	}
 }
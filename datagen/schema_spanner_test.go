@@ -0,0 +1,39 @@
+package datagen
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+)
+
+func TestSpannerSchemaTransactionKey(t *testing.T) {
+	ts := time.Unix(TransactionMinTime, 0)
+	tests := []struct {
+		schema string
+		want   spanner.Key
+		hint   string
+	}{
+		{schema: "key-id", want: spanner.Key{int64(1)}, hint: ""},
+		{schema: "key-from-user-and-time", want: spanner.Key{int64(2), ts}, hint: "@{FORCE_INDEX=UniqueId}"},
+		{schema: "interleaved", want: spanner.Key{int64(2), ts, int64(1)}, hint: "@{FORCE_INDEX=UniqueId}"},
+	}
+	for _, tt := range tests {
+		schema, err := LookupSpannerSchema(tt.schema)
+		if err != nil {
+			t.Fatalf("LookupSpannerSchema(%q) returned error: %v", tt.schema, err)
+		}
+		if got := schema.TransactionKey(1, 2, ts); got.String() != tt.want.String() {
+			t.Errorf("%s: TransactionKey() = %v, want %v", tt.schema, got, tt.want)
+		}
+		if got := schema.TransactionIDHint(); got != tt.hint {
+			t.Errorf("%s: TransactionIDHint() = %q, want %q", tt.schema, got, tt.hint)
+		}
+	}
+}
+
+func TestLookupSpannerSchemaUnknown(t *testing.T) {
+	if _, err := LookupSpannerSchema("key-unknown"); err == nil {
+		t.Error("LookupSpannerSchema(\"key-unknown\") succeeded, want an error")
+	}
+}
@@ -17,6 +17,7 @@ type TransactionGeneratorBigtable struct {
 	rand    *rand.Rand
 	rand2   *rand.Rand
 	metrics *timer.Metrics
+	rowKeys RowKeyStrategy
 }
 
 // NewTransactionGeneratorBigtable returns a new TransactionGeneratorBigtable instance.
@@ -24,14 +25,17 @@ func NewTransactionGeneratorBigtable(
 	ctx context.Context,
 	client *bigtable.Client,
 	metrics *timer.Metrics,
+	rowKeys RowKeyStrategy,
+	seed int64,
 ) *TransactionGeneratorBigtable {
 
 	return &TransactionGeneratorBigtable{
 		ctx:     ctx,
 		client:  client,
-		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
-		rand2:   rand.New(rand.NewSource(time.Now().UnixNano())),
+		rand:    NewRand(seed, "TransactionGenerator"),
+		rand2:   NewRand(seed, "TransactionGenerator.time"),
 		metrics: metrics,
+		rowKeys: rowKeys,
 	}
 }
 
@@ -46,6 +50,8 @@ func NewTransactionGeneratorBigtable(
 // int64. One of the reasons is because those keys are also stored as strings. Bigtable
 // documentation recommends the use of human-readable keys.
 //
+// Row keys are built from the generated fields by the row key strategy of the generator.
+//
 // See the links below for more information:
 //		https://cloud.google.com/bigtable/docs/schema-design#types_of_row_keys
 //
@@ -122,7 +128,8 @@ func (gen *TransactionGeneratorBigtable) generateForBucket(
 
 		timeSec := gen.rand2.Int63()%timeRange + TransactionMinTime
 		timeNanos := gen.rand2.Int63() % 1000000000
-		ts := bigtable.Time(time.Unix(timeSec, timeNanos))
+		txnTime := time.Unix(timeSec, timeNanos)
+		ts := bigtable.Time(txnTime)
 
 		// Although unrealistic, it's probably sufficient to only use "second" granularity here.
 		mutation := bigtable.NewMutation()
@@ -130,7 +137,12 @@ func (gen *TransactionGeneratorBigtable) generateForBucket(
 		mutation.Set(DefaultColumnFamily, TransactionFromUserColumn, ts, []byte(fromUserID))
 		mutation.Set(DefaultColumnFamily, TransactionToUserColumn, ts, []byte(toUserID))
 		mutations = append(mutations, mutation)
-		rowKeys = append(rowKeys, Int64String(TransactionBaseID+i))
+		rowKeys = append(rowKeys, gen.rowKeys.Encode(TransactionKey{
+			ID:         TransactionBaseID + i,
+			CompanyID:  companyID,
+			FromUserID: fromUserID,
+			Time:       txnTime,
+		}))
 	}
 	table := gen.client.Open(TransactionTableName)
 	if err := mergeErrors(table.ApplyBulk(gen.ctx, rowKeys, mutations)); err != nil {
@@ -18,6 +18,7 @@ type TransactionGeneratorSpanner struct {
 	rand    *rand.Rand
 	rand2   *rand.Rand
 	metrics *timer.Metrics
+	schema  SpannerSchema
 }
 
 // NewTransactionGeneratorSpanner returns a new TransactionGeneratorSpanner instance.
@@ -25,14 +26,17 @@ func NewTransactionGeneratorSpanner(
 	ctx context.Context,
 	client *spanner.Client,
 	metrics *timer.Metrics,
+	schema SpannerSchema,
+	seed int64,
 ) *TransactionGeneratorSpanner {
 
 	return &TransactionGeneratorSpanner{
 		ctx:     ctx,
 		client:  client,
-		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
-		rand2:   rand.New(rand.NewSource(time.Now().UnixNano())),
+		rand:    NewRand(seed, "TransactionGenerator"),
+		rand2:   NewRand(seed, "TransactionGenerator.time"),
 		metrics: metrics,
+		schema:  schema,
 	}
 }
 
@@ -54,11 +58,11 @@ func (gen *TransactionGeneratorSpanner) Generate() error {
 
 	// For referential integrity, we still need to ensure that transactions select from a list of
 	// valid company and user IDs.
-	companyIDs, err := gen.queryIds(CompanyTableName)
+	companyIDs, err := gen.queryIds(CompanyTableName, "Id")
 	if err != nil {
 		return err
 	}
-	userIDs, err := gen.queryIds(UserTableName)
+	userIDs, err := gen.queryIds(UserTableName, gen.schema.UserIDColumn)
 	if err != nil {
 		return err
 	}
@@ -82,11 +86,12 @@ func (gen *TransactionGeneratorSpanner) Generate() error {
 	return nil
 }
 
-func (gen *TransactionGeneratorSpanner) queryIds(tableName string) ([]int64, error) {
+func (gen *TransactionGeneratorSpanner) queryIds(tableName string, columnName string) ([]int64, error) {
 	defer gen.metrics.Track(time.Now(), fmt.Sprintf("TransactionGenerator.queryIds[%s]", tableName))
 
 	stmt := spanner.Statement{
-		SQL: fmt.Sprintf(`SELECT Id FROM %s`, tableName),
+		// IDs are ordered so that a seeded run picks the same references every time.
+		SQL: fmt.Sprintf(`SELECT %s FROM %s ORDER BY %s`, columnName, tableName, columnName),
 	}
 	start := time.Now()
 	iter := gen.client.Single().Query(gen.ctx, stmt)
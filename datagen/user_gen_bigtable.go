@@ -14,6 +14,7 @@ import (
 type UserGeneratorBigtable struct {
 	ctx     context.Context
 	client  *bigtable.Client
+	rand    *rand.Rand
 	metrics *timer.Metrics
 }
 
@@ -22,11 +23,13 @@ func NewUserGeneratorBigtable(
 	ctx context.Context,
 	client *bigtable.Client,
 	metrics *timer.Metrics,
+	seed int64,
 ) *UserGeneratorBigtable {
 
 	return &UserGeneratorBigtable{
 		ctx:     ctx,
 		client:  client,
+		rand:    NewRand(seed, "UserGenerator"),
 		metrics: metrics,
 	}
 }
@@ -72,7 +75,7 @@ func (gen *UserGeneratorBigtable) generateForBucket(min int, max int) error {
 			bigtable.Now(),
 			[]byte(fmt.Sprintf("User-%d", userIdx)))
 		mutations = append(mutations, mutation)
-		rowKeys = append(rowKeys, Int64String(rand.Int63()))
+		rowKeys = append(rowKeys, Int64String(gen.rand.Int63()))
 	}
 	table := gen.client.Open(UserTableName)
 	if err := mergeErrors(table.ApplyBulk(gen.ctx, rowKeys, mutations)); err != nil {
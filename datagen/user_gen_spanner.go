@@ -14,7 +14,9 @@ import (
 type UserGeneratorSpanner struct {
 	ctx     context.Context
 	client  *spanner.Client
+	rand    *rand.Rand
 	metrics *timer.Metrics
+	schema  SpannerSchema
 }
 
 // NewUserGeneratorSpanner returns a new UserGeneratorSpanner instance.
@@ -22,12 +24,16 @@ func NewUserGeneratorSpanner(
 	ctx context.Context,
 	client *spanner.Client,
 	metrics *timer.Metrics,
+	schema SpannerSchema,
+	seed int64,
 ) *UserGeneratorSpanner {
 
 	return &UserGeneratorSpanner{
 		ctx:     ctx,
 		client:  client,
+		rand:    NewRand(seed, "UserGenerator"),
 		metrics: metrics,
+		schema:  schema,
 	}
 }
 
@@ -62,9 +68,9 @@ func (gen *UserGeneratorSpanner) generateForBucket(min int, max int) error {
 	mutations := []*spanner.Mutation{}
 	for userIdx := min; userIdx < max; userIdx++ {
 		mutation := spanner.InsertMap(UserTableName, map[string]interface{}{
-			"id":           rand.Int63(),
-			"name":         fmt.Sprintf("User-%d", userIdx),
-			"creationTime": spanner.CommitTimestamp,
+			gen.schema.UserIDColumn: gen.rand.Int63(),
+			"name":                  fmt.Sprintf("User-%d", userIdx),
+			"creationTime":          spanner.CommitTimestamp,
 		})
 		mutations = append(mutations, mutation)
 	}
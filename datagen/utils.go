@@ -2,36 +2,36 @@ package datagen
 
 import (
 	"encoding/binary"
+	"hash/fnv"
 	"math/rand"
 	"strconv"
 )
 
-// RandomGeneratedTransactionID returns a randomly generated transaction ID within the valid range
-// of randomly generated transactions.
-func RandomGeneratedTransactionID(r *rand.Rand) int64 {
-	return TransactionBaseID + (r.Int63() % TransactionCount)
+// NewRand returns a random source for the named component, derived from a run-wide seed. Deriving
+// a separate source per component keeps each sequence reproducible on its own, regardless of what
+// other components were run or in which order.
+func NewRand(seed int64, name string) *rand.Rand {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return rand.New(rand.NewSource(seed ^ int64(h.Sum64())))
 }
 
-// RandomGeneratedTransactionIDString returns a randomly generated transaction ID within the valid
-// range of randomly generated transactions, as a string.
-func RandomGeneratedTransactionIDString(r *rand.Rand) string {
-	return Int64String(RandomGeneratedTransactionID(r))
+// ChooseTransactionID returns a transaction ID within the valid range of randomly generated
+// transactions, picked by the given chooser.
+func ChooseTransactionID(r *rand.Rand, keys KeyChooser) int64 {
+	return TransactionBaseID + keys.Choose(r)
 }
 
-// RandomGeneratedTransactionIDRange returns a randomly generated transaction ID range within the
-// valid range of randomly generated transactions, as a tuple of strings. The offset must be less
-// than the transaction count.
-func RandomGeneratedTransactionIDRange(r *rand.Rand, offset int64) (int64, int64) {
-	randomID := TransactionBaseID + (r.Int63() % (TransactionCount - offset))
-	return randomID, randomID + offset
-}
-
-// RandomGeneratedTransactionIDStringRange returns a randomly generated transaction ID range within
-// the valid range of randomly generated transactions, as a tuple of strings. The offset must be
-// less than the transaction count.
-func RandomGeneratedTransactionIDStringRange(r *rand.Rand, offset int64) (string, string) {
-	randomID := TransactionBaseID + (r.Int63() % (TransactionCount - offset))
-	return Int64String(randomID), Int64String(randomID + offset)
+// ChooseTransactionIDRange returns a transaction ID range within the valid range of randomly
+// generated transactions, starting at an ID picked by the given chooser. Ranges that would run past
+// the end of the valid range are moved back so that they end on the last valid ID. The offset
+// must be less than the transaction count.
+func ChooseTransactionIDRange(r *rand.Rand, keys KeyChooser, offset int64) (int64, int64) {
+	start := keys.Choose(r)
+	if start > TransactionCount-offset {
+		start = TransactionCount - offset
+	}
+	return TransactionBaseID + start, TransactionBaseID + start + offset
 }
 
 func int64Bytes(val int64) []byte {
@@ -8,4 +8,5 @@ require (
 	github.com/montanaflynn/stats v0.5.0
 	google.golang.org/api v0.10.0
 	google.golang.org/genproto v0.0.0-20190927181202-20e1ac93f88c
+	google.golang.org/grpc v1.21.1
 )
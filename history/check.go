@@ -0,0 +1,223 @@
+package history
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Result describes whether the history of a single register is linearizable.
+type Result struct {
+	Key string
+	// Ops is the number of OK operations, all of which must be linearized. Info operations are not
+	// counted, since they may never have taken effect.
+	Ops          int
+	Linearizable bool
+	// Linearized is the largest number of OK operations that could be put in a valid order before
+	// the search failed. It equals Ops when the history is linearizable.
+	Linearized int
+	// Pending lists the operations that could have come next when the search failed, which is
+	// where to start looking for the anomaly.
+	Pending []Op
+}
+
+// String describes the result in a single line, or more if the history is not linearizable.
+func (r Result) String() string {
+	if r.Linearizable {
+		return fmt.Sprintf("%s: linearizable (%d ops)", r.Key, r.Ops)
+	}
+	lines := []string{fmt.Sprintf("%s: NOT linearizable (%d ops, %d linearized)", r.Key, r.Ops, r.Linearized)}
+	for _, op := range r.Pending {
+		lines = append(lines, fmt.Sprintf("\tpending %s %q (%s) invoke=%d complete=%d",
+			op.F, op.Value, op.Outcome, op.Invoke, op.Complete))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Check verifies that the history of every key is linearizable, treating each key as an
+// independent read/write register. Results are ordered by key.
+//
+// The search follows Wing and Gong, with the memoization of Lowe: an operation can be linearized
+// next if it was invoked before any remaining operation completed, and the search backtracks
+// whenever a read does not observe the current value of the register. Failed operations are
+// ignored, and Info operations may be linearized at any point after they were invoked, or never.
+//
+// The initial value of each register is unknown, so the first read that is linearized before any
+// write defines it. Writing every register before the history starts avoids relying on this.
+//
+// See the links below for more information:
+//		https://github.com/jepsen-io/knossos
+//		https://arxiv.org/abs/1504.00204
+func Check(ops []Op) []Result {
+	opsByKey := map[string][]Op{}
+	for _, op := range ops {
+		if op.Outcome == Fail {
+			continue
+		}
+		opsByKey[op.Key] = append(opsByKey[op.Key], op)
+	}
+	keys := []string{}
+	for key := range opsByKey {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	results := []Result{}
+	for _, key := range keys {
+		results = append(results, newSearch(opsByKey[key]).run(key))
+	}
+	return results
+}
+
+// Linearizable returns whether every result is linearizable.
+func Linearizable(results []Result) bool {
+	for _, result := range results {
+		if !result.Linearizable {
+			return false
+		}
+	}
+	return true
+}
+
+// entry is an operation within the search, with its completion time moved to infinity if it may
+// take effect at any point after it was invoked.
+type entry struct {
+	op       Op
+	invoke   int64
+	complete int64
+	required bool
+}
+
+// search finds a linearization of the history of a single register.
+type search struct {
+	entries  []entry
+	done     []bool
+	required int
+	visited  map[string]bool
+	deepest  int
+	pending  []Op
+}
+
+func newSearch(ops []Op) *search {
+	entries := []entry{}
+	required := 0
+	for _, op := range ops {
+		e := entry{op: op, invoke: op.Invoke, complete: op.Complete, required: op.Outcome == OK}
+		if op.Outcome == Info {
+			// A read that did not complete observed nothing, so it cannot constrain the history.
+			if op.F == Read {
+				continue
+			}
+			e.complete = math.MaxInt64
+		}
+		if e.required {
+			required++
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].invoke < entries[j].invoke
+	})
+	return &search{
+		entries:  entries,
+		done:     make([]bool, len(entries)),
+		required: required,
+		visited:  map[string]bool{},
+	}
+}
+
+func (s *search) run(key string) Result {
+	ok := s.step(nil, 0)
+	result := Result{
+		Key:          key,
+		Ops:          s.required,
+		Linearizable: ok,
+		Linearized:   s.deepest,
+	}
+	if ok {
+		result.Linearized = s.required
+	} else {
+		result.Pending = s.pending
+	}
+	return result
+}
+
+// step tries every operation that could be linearized next, given the current value of the
+// register (nil if unknown) and the number of OK operations linearized so far.
+func (s *search) step(value *string, requiredDone int) bool {
+	if requiredDone == s.required {
+		return true
+	}
+	memo := s.memoKey(value)
+	if s.visited[memo] {
+		return false
+	}
+	s.visited[memo] = true
+
+	// Every candidate must be invoked before the earliest completion among the remaining
+	// operations, otherwise that operation would have to come first.
+	earliest := int64(math.MaxInt64)
+	for i, e := range s.entries {
+		if !s.done[i] && e.complete < earliest {
+			earliest = e.complete
+		}
+	}
+	candidates := []int{}
+	for i, e := range s.entries {
+		if e.invoke > earliest {
+			break
+		}
+		if !s.done[i] {
+			candidates = append(candidates, i)
+		}
+	}
+	if requiredDone >= s.deepest {
+		s.deepest = requiredDone
+		s.pending = []Op{}
+		for _, i := range candidates {
+			s.pending = append(s.pending, s.entries[i].op)
+		}
+	}
+
+	for _, i := range candidates {
+		e := s.entries[i]
+		next := value
+		switch e.op.F {
+		case Read:
+			if value != nil && *value != e.op.Value {
+				continue
+			}
+			observed := e.op.Value
+			next = &observed
+		case Write:
+			written := e.op.Value
+			next = &written
+		}
+		requiredNext := requiredDone
+		if e.required {
+			requiredNext++
+		}
+		s.done[i] = true
+		if s.step(next, requiredNext) {
+			return true
+		}
+		s.done[i] = false
+	}
+	return false
+}
+
+// memoKey identifies a state of the search by the set of linearized operations and the value of
+// the register. Reaching the same state twice cannot lead to a different outcome.
+func (s *search) memoKey(value *string) string {
+	bits := make([]byte, (len(s.done)+7)/8)
+	for i, done := range s.done {
+		if done {
+			bits[i/8] |= 1 << uint(i%8)
+		}
+	}
+	if value == nil {
+		return string(bits)
+	}
+	return string(bits) + "=" + *value
+}
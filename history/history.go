@@ -0,0 +1,103 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Function identifies what an operation does to a register.
+type Function string
+
+// Outcome identifies what is known about an operation once it returns.
+type Outcome string
+
+const (
+	// Read observes the value of a register.
+	Read Function = "read"
+	// Write replaces the value of a register.
+	Write Function = "write"
+
+	// OK means that the operation took effect, and that any value it observed is known.
+	OK Outcome = "ok"
+	// Fail means that the operation definitely did not take effect.
+	Fail Outcome = "fail"
+	// Info means that the operation may or may not have taken effect, such as a write that ran
+	// past its deadline. It may take effect at any point after it was invoked.
+	Info Outcome = "info"
+)
+
+// Op is a single operation against a register, from its invocation to its completion.
+type Op struct {
+	Key      string   `json:"key"`
+	F        Function `json:"f"`
+	Value    string   `json:"value"`
+	Invoke   int64    `json:"invoke"`
+	Complete int64    `json:"complete"`
+	Outcome  Outcome  `json:"outcome"`
+}
+
+// Recorder keeps the operation history of a run. It is safe for concurrent use by multiple
+// goroutines.
+type Recorder struct {
+	mu  sync.Mutex
+	ops []Op
+}
+
+// NewRecorder returns a new Recorder instance.
+func NewRecorder() *Recorder {
+	return &Recorder{ops: []Op{}}
+}
+
+// Record adds an operation that was invoked at start and completed just now. Reads must pass the
+// value that they observed, and writes the value that they wrote.
+func (rec *Recorder) Record(key string, f Function, value string, start time.Time, outcome Outcome) {
+	op := Op{
+		Key:      key,
+		F:        f,
+		Value:    value,
+		Invoke:   start.UnixNano(),
+		Complete: time.Now().UnixNano(),
+		Outcome:  outcome,
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.ops = append(rec.ops, op)
+}
+
+// Ops returns every operation recorded so far.
+func (rec *Recorder) Ops() []Op {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([]Op{}, rec.ops...)
+}
+
+// Write writes every operation recorded so far, one JSON object per line.
+func (rec *Recorder) Write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	for _, op := range rec.Ops() {
+		if err := encoder.Encode(op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Load reads a history that was written by Recorder.Write.
+func Load(r io.Reader) ([]Op, error) {
+	ops := []Op{}
+	decoder := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var op Op
+		if err := decoder.Decode(&op); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
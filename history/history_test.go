@@ -0,0 +1,81 @@
+package history
+
+import (
+	"testing"
+)
+
+// op builds an operation on key "k" that was invoked at invoke and completed at complete.
+func op(f Function, value string, invoke int64, complete int64, outcome Outcome) Op {
+	return Op{Key: "k", F: f, Value: value, Invoke: invoke, Complete: complete, Outcome: outcome}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name string
+		ops  []Op
+		want bool
+	}{
+		{
+			name: "sequential",
+			ops: []Op{
+				op(Write, "a", 0, 1, OK),
+				op(Read, "a", 2, 3, OK),
+				op(Write, "b", 4, 5, OK),
+				op(Read, "b", 6, 7, OK),
+			},
+			want: true,
+		},
+		{
+			name: "stale read",
+			ops: []Op{
+				op(Write, "a", 0, 1, OK),
+				op(Write, "b", 2, 3, OK),
+				op(Read, "a", 4, 5, OK),
+			},
+			want: false,
+		},
+		{
+			name: "concurrent overlap",
+			ops: []Op{
+				op(Write, "a", 0, 1, OK),
+				op(Write, "b", 2, 6, OK),
+				op(Read, "a", 3, 4, OK),
+				op(Read, "b", 5, 8, OK),
+				op(Read, "b", 7, 9, OK),
+			},
+			want: true,
+		},
+		{
+			name: "info write observed later",
+			ops: []Op{
+				op(Write, "a", 0, 1, OK),
+				op(Write, "b", 2, 3, Info),
+				op(Read, "a", 4, 5, OK),
+				op(Read, "b", 6, 7, OK),
+			},
+			want: true,
+		},
+		{
+			name: "read of a never-written value",
+			ops: []Op{
+				op(Write, "a", 0, 1, OK),
+				op(Read, "c", 2, 3, OK),
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := Check(tt.ops)
+			if len(results) != 1 {
+				t.Fatalf("Check returned %d results, want 1", len(results))
+			}
+			if got := results[0].Linearizable; got != tt.want {
+				t.Errorf("Check(%s).Linearizable = %v, want %v\n%s", tt.name, got, tt.want, results[0])
+			}
+			if got := Linearizable(results); got != tt.want {
+				t.Errorf("Linearizable(%s) = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
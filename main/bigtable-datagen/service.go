@@ -7,6 +7,8 @@ import (
 	"io"
 	"log"
 	"os"
+	"strings"
+	"time"
 
 	"cloud.google.com/go/bigtable"
 	"github.com/r7wang/gcloud-test/datagen"
@@ -37,32 +39,51 @@ func run(
 	adminClient *bigtable.AdminClient,
 	dataClient *bigtable.Client,
 	w io.Writer,
+	rowKeysName string,
+	seed int64,
 ) error {
 
 	metrics := timer.NewMetrics()
+	fmt.Fprintf(w, "Using seed [%d]\n", seed)
 
+	rowKeys, err := datagen.ParseRowKeyStrategy(rowKeysName)
+	if err != nil {
+		fmt.Fprintf(w, "Failed to instantiate schema: %v\n", err)
+		return err
+	}
 	schema := datagen.NewSchemaBigtable(ctx, adminClient)
 	if err := schema.CreateTables(); err != nil {
 		fmt.Fprintf(w, "Failed to instantiate schema: %v\n", err)
 		return err
 	}
-	fmt.Fprintf(w, "Created schema\n")
+	if err := datagen.WriteRowKeyStrategy(ctx, dataClient, rowKeys.Name()); err != nil {
+		fmt.Fprintf(w, "Failed to record row key strategy: %v\n", err)
+		return err
+	}
+	fmt.Fprintf(w, "Created schema with row keys [%s]\n", rowKeys.Name())
 
-	companyGen := datagen.NewCompanyGeneratorBigtable(ctx, dataClient, metrics)
+	companyGen := datagen.NewCompanyGeneratorBigtable(ctx, dataClient, metrics, seed)
 	if err := companyGen.Generate(); err != nil {
 		fmt.Fprintf(w, "Failed to generate companies: %v\n", err)
 		return err
 	}
 	fmt.Fprintf(w, "Inserted companies\n")
 
-	userGen := datagen.NewUserGeneratorBigtable(ctx, dataClient, metrics)
+	userGen := datagen.NewUserGeneratorBigtable(ctx, dataClient, metrics, seed)
 	if err := userGen.Generate(); err != nil {
 		fmt.Fprintf(w, "Failed to generate users: %v\n", err)
 		return err
 	}
 	fmt.Fprintf(w, "Inserted users\n")
 
-	transactionGen := datagen.NewTransactionGeneratorBigtable(ctx, dataClient, metrics)
+	accountGen := datagen.NewAccountGeneratorBigtable(ctx, dataClient, metrics)
+	if err := accountGen.Generate(); err != nil {
+		fmt.Fprintf(w, "Failed to generate accounts: %v\n", err)
+		return err
+	}
+	fmt.Fprintf(w, "Inserted accounts\n")
+
+	transactionGen := datagen.NewTransactionGeneratorBigtable(ctx, dataClient, metrics, rowKeys, seed)
 	if err := transactionGen.Generate(); err != nil {
 		fmt.Fprintf(w, "Failed to generate transactions: %v\n", err)
 		return err
@@ -73,16 +94,25 @@ func run(
 	if err != nil {
 		fmt.Fprintf(w, "Failed to summarize metrics: %v\n", err)
 	}
-	fmt.Fprintf(w, summary)
+	fmt.Fprintln(w, summary)
 	return nil
 }
 
 func main() {
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, `Usage: bigtable-datagen <project_name> <instance_name>`)
+		fmt.Fprintf(os.Stderr, "Usage: bigtable-datagen [flags] <project_name> <instance_name>\n")
+		flag.PrintDefaults()
 	}
 
+	seed := flag.Int64("seed", 0, "seed for generated IDs and field values (0 picks one at random)")
+	rowKeysName := flag.String(
+		"row-keys",
+		datagen.DefaultRowKeyStrategy,
+		fmt.Sprintf("row key strategy of the transactions table: %s", strings.Join(datagen.RowKeyStrategyNames(), ", ")))
 	flag.Parse()
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
 	flagCount := len(flag.Args())
 	if flagCount != 2 {
 		flag.Usage()
@@ -96,7 +126,7 @@ func main() {
 	defer adminClient.Close()
 	defer dataClient.Close()
 
-	if err := run(ctx, adminClient, dataClient, os.Stdout); err != nil {
+	if err := run(ctx, adminClient, dataClient, os.Stdout, *rowKeysName, *seed); err != nil {
 		os.Exit(1)
 	}
 }
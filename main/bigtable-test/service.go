@@ -7,10 +7,15 @@ import (
 	"io"
 	"log"
 	"os"
+	"time"
 
 	"cloud.google.com/go/bigtable"
+	"github.com/r7wang/gcloud-test/datagen"
+	"github.com/r7wang/gcloud-test/history"
 	"github.com/r7wang/gcloud-test/timer"
 	"github.com/r7wang/gcloud-test/workflow"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func createClients(
@@ -30,30 +35,151 @@ func run(
 	ctx context.Context,
 	client *bigtable.Client,
 	w io.Writer,
+	config workflow.Config,
+	spec *workflow.Spec,
 ) error {
 
 	metrics := timer.NewMetrics()
-
-	oltp := workflow.NewOLTPBigtable(ctx, client, metrics)
-	if err := oltp.Run(); err != nil {
-		fmt.Fprintf(w, "Failed to run transactional workflow: %v\n", err)
+	defer printSummary(w, metrics)
+	fmt.Fprintf(w, "Using seed [%d]\n", config.Seed)
+	rowKeysName, err := datagen.ReadRowKeyStrategy(ctx, client)
+	if status.Code(err) == codes.NotFound {
+		// Instances generated before the row key strategy was recorded have no metadata table,
+		// and were always generated with the default strategy.
+		rowKeysName = datagen.DefaultRowKeyStrategy
+	} else if err != nil {
+		fmt.Fprintf(w, "Failed to read row keys: %v\n", err)
+		return err
+	}
+	if config.RowKeys, err = datagen.ParseRowKeyStrategy(rowKeysName); err != nil {
+		fmt.Fprintf(w, "Failed to instantiate row keys: %v\n", err)
 		return err
 	}
+	fmt.Fprintf(w, "Using row keys [%s]\n", config.RowKeys.Name())
+
+	oltp := workflow.NewOLTPBigtable(ctx, client, metrics, config)
+	olap := workflow.NewOLAPBigtable(ctx, client, metrics, config)
+	ledger := workflow.NewLedger(ctx, workflow.NewBackendBigtable(client, config.RowKeys), metrics, config)
+	bulk := workflow.NewBulkBigtable(ctx, client, metrics, config)
+	if spec != nil {
+		fmt.Fprintf(w, "Running workload [%s]\n", spec.Name)
+		if err := spec.Run(config, oltp, olap, ledger, bulk); err != nil {
+			fmt.Fprintf(w, "Failed to run workload: %v\n", err)
+			return err
+		}
+	} else {
+		if err := oltp.Run(); err != nil {
+			fmt.Fprintf(w, "Failed to run transactional workflow: %v\n", err)
+			return err
+		}
+
+		if err := olap.Run(); err != nil {
+			fmt.Fprintf(w, "Failed to run analytical workflow: %v\n", err)
+			return err
+		}
+
+		if config.Ledger {
+			if !config.RowKeys.Addressable() {
+				fmt.Fprintf(w, "Skipping ID-addressed ledger tests, row keys [%s] cannot be built from IDs\n", config.RowKeys.Name())
+			}
+			if err := ledger.Run(); err != nil {
+				fmt.Fprintf(w, "Failed to run ledger workflow: %v\n", err)
+				return err
+			}
+		}
+
+		if config.BulkSamples > 0 {
+			if err := bulk.Run(); err != nil {
+				fmt.Fprintf(w, "Failed to run bulk workflow: %v\n", err)
+				return err
+			}
+		}
+	}
 
+	return nil
+}
+
+// printSummary writes the metric summary. It also runs when a workflow fails, so that the samples
+// collected up to that point are not lost.
+func printSummary(w io.Writer, metrics *timer.Metrics) {
 	summary, err := metrics.Summarize()
 	if err != nil {
 		fmt.Fprintf(w, "Failed to summarize metrics: %v\n", err)
 	}
-	fmt.Fprintf(w, summary)
-	return nil
+	fmt.Fprintln(w, summary)
+}
+
+// writeHistory writes every recorded operation to path, even if a workflow failed, since the
+// operations recorded up to that point can still be checked.
+func writeHistory(path string, rec *history.Recorder) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := rec.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// listTests writes the name of every selected test. Nothing is run, so the workflows are created
+// without a client.
+func listTests(w io.Writer, config workflow.Config, spec *workflow.Spec) {
+	var names []string
+	if spec != nil {
+		names = spec.SelectedTests(config)
+	} else {
+		ctx := context.Background()
+		metrics := timer.NewMetrics()
+		suites := []workflow.Suite{
+			workflow.NewOLTPBigtable(ctx, nil, metrics, config),
+			workflow.NewOLAPBigtable(ctx, nil, metrics, config),
+		}
+		if config.Ledger {
+			suites = append(suites, workflow.NewLedger(ctx, workflow.NewBackendBigtable(nil, config.RowKeys), metrics, config))
+		}
+		if config.BulkSamples > 0 {
+			suites = append(suites, workflow.NewBulkBigtable(ctx, nil, metrics, config))
+		}
+		names = workflow.SelectedTests(config, suites...)
+	}
+	for _, name := range names {
+		fmt.Fprintln(w, name)
+	}
 }
 
 func main() {
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, `Usage: bigtable-test <project_name> <instance_name>`)
+		fmt.Fprintf(os.Stderr, "Usage: bigtable-test [flags] <project_name> <instance_name>\n")
+		flag.PrintDefaults()
 	}
 
+	config := workflow.DefaultConfig()
+	config.RegisterFlags(flag.CommandLine)
+	specPath := flag.String("workload", "", "path to a JSON workload spec, replacing the default test sequence")
+	list := flag.Bool("list", false, "list the selected tests without running them")
+	historyPath := flag.String("history", "", "path to record the operation history of the register tests to, for history-check")
 	flag.Parse()
+	if config.Seed == 0 {
+		config.Seed = time.Now().UnixNano()
+	}
+	if *historyPath != "" {
+		config.History = history.NewRecorder()
+	}
+
+	var spec *workflow.Spec
+	if *specPath != "" {
+		var err error
+		if spec, err = workflow.LoadSpec(*specPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *list {
+		listTests(os.Stdout, config, spec)
+		return
+	}
+
 	flagCount := len(flag.Args())
 	if flagCount != 2 {
 		flag.Usage()
@@ -66,7 +192,13 @@ func main() {
 	client := createClients(ctx, projectName, instanceName)
 	defer client.Close()
 
-	if err := run(ctx, client, os.Stdout); err != nil {
+	err := run(ctx, client, os.Stdout, config, spec)
+	if config.History != nil {
+		if err := writeHistory(*historyPath, config.History); err != nil {
+			log.Printf("Failed to write history: %v", err)
+		}
+	}
+	if err != nil {
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/r7wang/gcloud-test/history"
+)
+
+// run checks that the history in path is linearizable, writing the result for every key. It
+// returns whether every key is linearizable.
+func run(w io.Writer, path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	ops, err := history.Load(f)
+	if err != nil {
+		return false, err
+	}
+
+	results := history.Check(ops)
+	for _, result := range results {
+		fmt.Fprintln(w, result)
+	}
+	ok := history.Linearizable(results)
+	if ok {
+		fmt.Fprintf(w, "History is linearizable (%d ops across %d keys)\n", len(ops), len(results))
+	} else {
+		fmt.Fprintf(w, "History is NOT linearizable\n")
+	}
+	return ok, nil
+}
+
+// history-check verifies offline that an operation history recorded by the test binaries with
+// -history is linearizable, treating each key as an independent read/write register.
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: history-check <history_path>\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if len(flag.Args()) != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ok, err := run(os.Stdout, flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
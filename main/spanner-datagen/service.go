@@ -7,6 +7,8 @@ import (
 	"io"
 	"log"
 	"os"
+	"strings"
+	"time"
 
 	"cloud.google.com/go/spanner"
 	database "cloud.google.com/go/spanner/admin/database/apiv1"
@@ -35,32 +37,50 @@ func run(
 	dataClient *spanner.Client,
 	w io.Writer,
 	db string,
+	schemaName string,
+	seed int64,
 ) error {
 
 	metrics := timer.NewMetrics()
+	fmt.Fprintf(w, "Using seed [%d]\n", seed)
 
-	schema := datagen.NewSchemaSpanner(ctx, adminClient)
-	if err := schema.CreateDatabase(db); err != nil {
+	schema, err := datagen.LookupSpannerSchema(schemaName)
+	if err != nil {
 		fmt.Fprintf(w, "Failed to instantiate schema: %v\n", err)
 		return err
 	}
-	fmt.Fprintf(w, "Created database [%s]\n", db)
+	if err := datagen.NewSchemaSpanner(ctx, adminClient).CreateDatabase(db, schema); err != nil {
+		fmt.Fprintf(w, "Failed to instantiate schema: %v\n", err)
+		return err
+	}
+	if err := datagen.WriteSchemaName(ctx, dataClient, schemaName); err != nil {
+		fmt.Fprintf(w, "Failed to record schema: %v\n", err)
+		return err
+	}
+	fmt.Fprintf(w, "Created database [%s] with schema [%s]\n", db, schemaName)
 
-	companyGen := datagen.NewCompanyGeneratorSpanner(ctx, dataClient, metrics)
+	companyGen := datagen.NewCompanyGeneratorSpanner(ctx, dataClient, metrics, seed)
 	if err := companyGen.Generate(); err != nil {
 		fmt.Fprintf(w, "Failed to generate companies: %v\n", err)
 		return err
 	}
 	fmt.Fprintf(w, "Inserted companies\n")
 
-	userGen := datagen.NewUserGeneratorSpanner(ctx, dataClient, metrics)
+	userGen := datagen.NewUserGeneratorSpanner(ctx, dataClient, metrics, schema, seed)
 	if err := userGen.Generate(); err != nil {
 		fmt.Fprintf(w, "Failed to generate users: %v\n", err)
 		return err
 	}
 	fmt.Fprintf(w, "Inserted users\n")
 
-	transactionGen := datagen.NewTransactionGeneratorSpanner(ctx, dataClient, metrics)
+	accountGen := datagen.NewAccountGeneratorSpanner(ctx, dataClient, metrics, schema)
+	if err := accountGen.Generate(); err != nil {
+		fmt.Fprintf(w, "Failed to generate accounts: %v\n", err)
+		return err
+	}
+	fmt.Fprintf(w, "Inserted accounts\n")
+
+	transactionGen := datagen.NewTransactionGeneratorSpanner(ctx, dataClient, metrics, schema, seed)
 	if err := transactionGen.Generate(); err != nil {
 		fmt.Fprintf(w, "Failed to generate transactions: %v\n", err)
 		return err
@@ -71,7 +91,7 @@ func run(
 	if err != nil {
 		fmt.Fprintf(w, "Failed to summarize metrics: %v\n", err)
 	}
-	fmt.Fprintf(w, summary)
+	fmt.Fprintln(w, summary)
 	return nil
 }
 
@@ -80,10 +100,19 @@ func run(
 // inserts.
 func main() {
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, `Usage: spanner-datagen <database_name>`)
+		fmt.Fprintf(os.Stderr, "Usage: spanner-datagen [flags] <database_name>\n")
+		flag.PrintDefaults()
 	}
 
+	seed := flag.Int64("seed", 0, "seed for generated IDs and field values (0 picks one at random)")
+	schemaName := flag.String(
+		"schema",
+		datagen.DefaultSpannerSchema,
+		fmt.Sprintf("schema variant to create: %s", strings.Join(datagen.SpannerSchemaNames(), ", ")))
 	flag.Parse()
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
 	flagCount := len(flag.Args())
 	if flagCount != 1 {
 		flag.Usage()
@@ -96,7 +125,7 @@ func main() {
 	defer adminClient.Close()
 	defer dataClient.Close()
 
-	if err := run(ctx, adminClient, dataClient, os.Stdout, db); err != nil {
+	if err := run(ctx, adminClient, dataClient, os.Stdout, db, *schemaName, *seed); err != nil {
 		os.Exit(1)
 	}
 }
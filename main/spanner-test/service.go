@@ -7,10 +7,14 @@ import (
 	"io"
 	"log"
 	"os"
+	"time"
 
 	"cloud.google.com/go/spanner"
+	"github.com/r7wang/gcloud-test/datagen"
+	"github.com/r7wang/gcloud-test/history"
 	"github.com/r7wang/gcloud-test/timer"
 	"github.com/r7wang/gcloud-test/workflow"
+	"google.golang.org/grpc/codes"
 )
 
 func createClients(ctx context.Context, db string) *spanner.Client {
@@ -27,36 +31,148 @@ func run(
 	client *spanner.Client,
 	w io.Writer,
 	db string,
+	config workflow.Config,
+	spec *workflow.Spec,
 ) error {
 
 	metrics := timer.NewMetrics()
-
-	oltp := workflow.NewOLTPSpanner(ctx, client, metrics)
-	if err := oltp.Run(); err != nil {
-		fmt.Fprintf(w, "Failed to run transactional workflow: %v\n", err)
+	defer printSummary(w, metrics)
+	fmt.Fprintf(w, "Using seed [%d]\n", config.Seed)
+	schemaName, err := datagen.ReadSchemaName(ctx, client)
+	if spanner.ErrCode(err) == codes.NotFound {
+		// Databases generated before the schema was recorded have no metadata table, and were
+		// always created with the default schema.
+		schemaName = datagen.DefaultSpannerSchema
+	} else if err != nil {
+		fmt.Fprintf(w, "Failed to read schema: %v\n", err)
 		return err
 	}
-
-	olap := workflow.NewOLAPSpanner(ctx, client, metrics)
-	if err := olap.Run(); err != nil {
-		fmt.Fprintf(w, "Failed to run analytical workflow: %v\n", err)
+	if config.Schema, err = datagen.LookupSpannerSchema(schemaName); err != nil {
+		fmt.Fprintf(w, "Failed to instantiate schema: %v\n", err)
 		return err
 	}
+	fmt.Fprintf(w, "Using schema [%s]\n", config.Schema.Name)
+
+	oltp := workflow.NewOLTPSpanner(ctx, client, metrics, config)
+	olap := workflow.NewOLAPSpanner(ctx, client, metrics, config)
+	ledger := workflow.NewLedger(ctx, workflow.NewBackendSpanner(client, config.Schema), metrics, config)
+	bulk := workflow.NewBulkSpanner(ctx, client, metrics, config)
+	if spec != nil {
+		fmt.Fprintf(w, "Running workload [%s]\n", spec.Name)
+		if err := spec.Run(config, oltp, olap, ledger, bulk); err != nil {
+			fmt.Fprintf(w, "Failed to run workload: %v\n", err)
+			return err
+		}
+	} else {
+		if err := oltp.Run(); err != nil {
+			fmt.Fprintf(w, "Failed to run transactional workflow: %v\n", err)
+			return err
+		}
+
+		if err := olap.Run(); err != nil {
+			fmt.Fprintf(w, "Failed to run analytical workflow: %v\n", err)
+			return err
+		}
+
+		if config.Ledger {
+			if err := ledger.Run(); err != nil {
+				fmt.Fprintf(w, "Failed to run ledger workflow: %v\n", err)
+				return err
+			}
+		}
+
+		if config.BulkSamples > 0 {
+			if err := bulk.Run(); err != nil {
+				fmt.Fprintf(w, "Failed to run bulk workflow: %v\n", err)
+				return err
+			}
+		}
+	}
 
+	return nil
+}
+
+// printSummary writes the metric summary. It also runs when a workflow fails, so that the samples
+// collected up to that point are not lost.
+func printSummary(w io.Writer, metrics *timer.Metrics) {
 	summary, err := metrics.Summarize()
 	if err != nil {
 		fmt.Fprintf(w, "Failed to summarize metrics: %v\n", err)
 	}
-	fmt.Fprintf(w, summary)
-	return nil
+	fmt.Fprintln(w, summary)
+}
+
+// writeHistory writes every recorded operation to path, even if a workflow failed, since the
+// operations recorded up to that point can still be checked.
+func writeHistory(path string, rec *history.Recorder) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := rec.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// listTests writes the name of every selected test. Nothing is run, so the workflows are created
+// without a client.
+func listTests(w io.Writer, config workflow.Config, spec *workflow.Spec) {
+	var names []string
+	if spec != nil {
+		names = spec.SelectedTests(config)
+	} else {
+		ctx := context.Background()
+		metrics := timer.NewMetrics()
+		suites := []workflow.Suite{
+			workflow.NewOLTPSpanner(ctx, nil, metrics, config),
+			workflow.NewOLAPSpanner(ctx, nil, metrics, config),
+		}
+		if config.Ledger {
+			suites = append(suites, workflow.NewLedger(ctx, workflow.NewBackendSpanner(nil, config.Schema), metrics, config))
+		}
+		if config.BulkSamples > 0 {
+			suites = append(suites, workflow.NewBulkSpanner(ctx, nil, metrics, config))
+		}
+		names = workflow.SelectedTests(config, suites...)
+	}
+	for _, name := range names {
+		fmt.Fprintln(w, name)
+	}
 }
 
 func main() {
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, `Usage: spanner-test <database_name>`)
+		fmt.Fprintf(os.Stderr, "Usage: spanner-test [flags] <database_name>\n")
+		flag.PrintDefaults()
 	}
 
+	config := workflow.DefaultConfig()
+	config.RegisterFlags(flag.CommandLine)
+	specPath := flag.String("workload", "", "path to a JSON workload spec, replacing the default test sequence")
+	list := flag.Bool("list", false, "list the selected tests without running them")
+	historyPath := flag.String("history", "", "path to record the operation history of the register tests to, for history-check")
 	flag.Parse()
+	if config.Seed == 0 {
+		config.Seed = time.Now().UnixNano()
+	}
+	if *historyPath != "" {
+		config.History = history.NewRecorder()
+	}
+
+	var spec *workflow.Spec
+	if *specPath != "" {
+		var err error
+		if spec, err = workflow.LoadSpec(*specPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *list {
+		listTests(os.Stdout, config, spec)
+		return
+	}
+
 	flagCount := len(flag.Args())
 	if flagCount != 1 {
 		flag.Usage()
@@ -68,7 +184,13 @@ func main() {
 	client := createClients(ctx, db)
 	defer client.Close()
 
-	if err := run(ctx, client, os.Stdout, db); err != nil {
+	err := run(ctx, client, os.Stdout, db, config, spec)
+	if config.History != nil {
+		if err := writeHistory(*historyPath, config.History); err != nil {
+			log.Printf("Failed to write history: %v", err)
+		}
+	}
+	if err != nil {
 		os.Exit(1)
 	}
 }
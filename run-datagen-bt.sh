@@ -23,4 +23,4 @@ gcloud bigtable instances create ${INSTANCE_NAME} \
 	--cluster-storage-type="ssd" \
 	--instance-type="PRODUCTION"
 
-${CMD} ${PROJECT_NAME} ${INSTANCE_NAME}
+${CMD} "$@" ${PROJECT_NAME} ${INSTANCE_NAME}
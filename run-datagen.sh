@@ -21,5 +21,5 @@ gcloud spanner instances create ${INSTANCE_NAME} \
 	--description="Ledger" \
 	--nodes=3
 
-${CMD} ${DB_PATH}
+${CMD} "$@" ${DB_PATH}
 
@@ -0,0 +1,13 @@
+#!/usr/bin/env bash
+
+DIR="$( cd "$( dirname "${BASH_SOURCE[0]}" )" && pwd )"
+OS=$(go env GOOS)
+ARCH=$(go env GOARCH)
+
+# Name of the experiment being run.
+ENTITY_NAME="history-check"
+
+# Path to the executable.
+CMD="${DIR}/build/${OS}-${ARCH}/${ENTITY_NAME}"
+
+${CMD} "$@"
@@ -12,5 +12,5 @@ ENTITY_NAME="bigtable-test"
 # Path to the executable.
 CMD="${DIR}/build/${OS}-${ARCH}/${ENTITY_NAME}"
 
-${CMD} ${PROJECT_NAME} ${INSTANCE_NAME}
+${CMD} "$@" ${PROJECT_NAME} ${INSTANCE_NAME}
 
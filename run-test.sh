@@ -16,5 +16,5 @@ CMD="${DIR}/build/${OS}-${ARCH}/${ENTITY_NAME}"
 DB_NAME="ledger"
 DB_PATH="projects/${PROJECT_NAME}/instances/${INSTANCE_NAME}/databases/${DB_NAME}"
 
-${CMD} ${DB_PATH}
+${CMD} "$@" ${DB_PATH}
 
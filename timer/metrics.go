@@ -3,27 +3,64 @@ package timer
 import (
 	"fmt"
 	"log"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/montanaflynn/stats"
 )
 
-// Metrics provides utilities to track performance metrics by metric name.
+// Metrics provides utilities to track performance metrics by metric name. It is safe for
+// concurrent use by multiple goroutines.
 type Metrics struct {
+	mu              sync.Mutex
 	durationsByName map[string][]int64
+	countsByName    map[string]int64
+	valuesByName    map[string][]int64
+	elapsedByName   map[string][]time.Duration
+	ratiosByName    map[string][2]string
+	errorsByName    map[string]map[string]int64
+	stepsByName     map[string][]StepResult
+}
+
+// StepResult describes the outcome of holding a fixed offered load for one step of a step-load
+// run.
+type StepResult struct {
+	OfferedQPS float64
+	Throughput float64
+	Successes  int64
+	Errors     int64
+	Median     time.Duration
+	Pct99      time.Duration
+}
+
+// ErrorRate returns the fraction of samples in the step that failed.
+func (r StepResult) ErrorRate() float64 {
+	if r.Successes+r.Errors == 0 {
+		return 0
+	}
+	return float64(r.Errors) / float64(r.Successes+r.Errors)
 }
 
 // NewMetrics returns a new Metrics instance.
 func NewMetrics() *Metrics {
 	return &Metrics{
 		durationsByName: make(map[string][]int64),
+		countsByName:    make(map[string]int64),
+		valuesByName:    make(map[string][]int64),
+		elapsedByName:   make(map[string][]time.Duration),
+		ratiosByName:    make(map[string][2]string),
+		errorsByName:    make(map[string]map[string]int64),
+		stepsByName:     make(map[string][]StepResult),
 	}
 }
 
 // Track keeps track of time taken to run an operation function.
 func (m *Metrics) Track(start time.Time, name string) {
 	elapsed := time.Since(start)
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	if _, ok := m.durationsByName[name]; !ok {
 		m.durationsByName[name] = []int64{}
 	}
@@ -31,6 +68,101 @@ func (m *Metrics) Track(start time.Time, name string) {
 	log.Printf("(%d) %s took %s", len(m.durationsByName[name]), name, elapsed)
 }
 
+// Count increments a counter for events that do not have a duration, such as missed samples.
+func (m *Metrics) Count(name string) {
+	m.Add(name, 1)
+}
+
+// Add increases a counter by delta, for quantities that are not one per event, such as the number
+// of rows affected by a bulk change.
+func (m *Metrics) Add(name string, delta int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.countsByName[name] += delta
+}
+
+// Observe keeps track of a quantity measured once per sample, such as the number of partitions
+// that a query was split into. Unlike Add, the quantity is summarized as a distribution.
+func (m *Metrics) Observe(name string, value int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.valuesByName[name] = append(m.valuesByName[name], value)
+}
+
+// Ratio summarizes the counter numerator as a percentage of the counter denominator under the
+// given name, such as the fraction of attempts that were aborted.
+func (m *Metrics) Ratio(name string, numerator string, denominator string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ratiosByName[name] = [2]string{numerator, denominator}
+}
+
+// Elapsed keeps track of the wall time of an operation that only runs a handful of times, such as
+// a bulk change. Unlike Track, every run is summarized, however few there are.
+func (m *Metrics) Elapsed(start time.Time, name string) {
+	elapsed := time.Since(start)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.elapsedByName[name] = append(m.elapsedByName[name], elapsed)
+	log.Printf("(%d) %s took %s", len(m.elapsedByName[name]), name, elapsed)
+}
+
+// TrackError keeps track of a failed operation, by the class of error that caused it to fail.
+func (m *Metrics) TrackError(name string, class string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.errorsByName[name]; !ok {
+		m.errorsByName[name] = make(map[string]int64)
+	}
+	m.errorsByName[name][class]++
+	log.Printf("%s failed with %s", name, class)
+}
+
+// StepResult computes the throughput and latency percentiles of every sample tracked under name,
+// which is expected to hold the samples of a single step that ran for the given elapsed time.
+func (m *Metrics) StepResult(name string, offeredQPS float64, elapsed time.Duration) (StepResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := StepResult{
+		OfferedQPS: offeredQPS,
+		Successes:  int64(len(m.durationsByName[name])),
+	}
+	for _, count := range m.errorsByName[name] {
+		result.Errors += count
+	}
+	result.Throughput = float64(result.Successes) / elapsed.Seconds()
+	if result.Successes == 0 {
+		return result, nil
+	}
+	raw := stats.LoadRawData(m.durationsByName[name])
+	median, err := stats.Median(raw)
+	if err != nil {
+		return result, err
+	}
+	pct99, err := stats.Percentile(raw, 99)
+	if err != nil {
+		return result, err
+	}
+	result.Median = time.Duration(median)
+	result.Pct99 = time.Duration(pct99)
+	return result, nil
+}
+
+// RecordStep adds a step to the throughput versus latency curve of an operation.
+func (m *Metrics) RecordStep(name string, result StepResult) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stepsByName[name] = append(m.stepsByName[name], result)
+	log.Printf("%s at %.2fqps: throughput=%.2fqps, median=%s, pct99=%s, errorRate=%.2f%%",
+		name,
+		result.OfferedQPS,
+		result.Throughput,
+		result.Median,
+		result.Pct99,
+		100*result.ErrorRate())
+}
+
 // Summarize aggregates the metric results into a human-readable string.
 func (m *Metrics) Summarize() (string, error) {
 	const minSamples = 100
@@ -39,6 +171,9 @@ func (m *Metrics) Summarize() (string, error) {
 	const ignoredSamples = 10
 	const nanosInMillis float64 = 1000000
 
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	summaries := []string{}
 	for name, durationsForName := range m.durationsByName {
 		numSamples := len(durationsForName)
@@ -69,7 +204,94 @@ func (m *Metrics) Summarize() (string, error) {
 			median/nanosInMillis,
 			pct75/nanosInMillis,
 			pct99/nanosInMillis)
+		if _, ok := m.errorsByName[name]; ok {
+			summary = fmt.Sprintf("%s, %s", summary, m.summarizeErrors(name))
+		}
 		summaries = append(summaries, summary)
 	}
+	// Operations that failed too often may not have enough successful samples to be summarized
+	// above, but their errors should still be reported.
+	for name := range m.errorsByName {
+		if len(m.durationsByName[name]) < minSamples {
+			summaries = append(summaries, fmt.Sprintf("%s: %s", name, m.summarizeErrors(name)))
+		}
+	}
+	for name, runs := range m.elapsedByName {
+		var total time.Duration
+		for _, elapsed := range runs {
+			total += elapsed
+		}
+		summaries = append(summaries, fmt.Sprintf("%s: runs=%d, total=%.2fms, mean=%.2fms",
+			name,
+			len(runs),
+			float64(total.Nanoseconds())/nanosInMillis,
+			float64(total.Nanoseconds())/nanosInMillis/float64(len(runs))))
+	}
+	for name, count := range m.countsByName {
+		summaries = append(summaries, fmt.Sprintf("%s: count=%d", name, count))
+	}
+	for name, values := range m.valuesByName {
+		min, max, total := values[0], values[0], int64(0)
+		for _, value := range values {
+			if value < min {
+				min = value
+			}
+			if value > max {
+				max = value
+			}
+			total += value
+		}
+		summaries = append(summaries, fmt.Sprintf("%s: samples=%d, min=%d, mean=%.2f, max=%d",
+			name,
+			len(values),
+			min,
+			float64(total)/float64(len(values)),
+			max))
+	}
+	for name, counters := range m.ratiosByName {
+		numerator, denominator := m.countsByName[counters[0]], m.countsByName[counters[1]]
+		if denominator == 0 {
+			continue
+		}
+		summaries = append(summaries, fmt.Sprintf("%s: %.2f%% (%d/%d)",
+			name,
+			100*float64(numerator)/float64(denominator),
+			numerator,
+			denominator))
+	}
+	for name, steps := range m.stepsByName {
+		curve := []string{fmt.Sprintf("%s [CURVE]:", name)}
+		for _, step := range steps {
+			curve = append(curve, fmt.Sprintf("\toffered=%.2fqps, throughput=%.2fqps, median=%.2fms, pct99=%.2fms, errorRate=%.2f%%",
+				step.OfferedQPS,
+				step.Throughput,
+				float64(step.Median.Nanoseconds())/nanosInMillis,
+				float64(step.Pct99.Nanoseconds())/nanosInMillis,
+				100*step.ErrorRate()))
+		}
+		summaries = append(summaries, strings.Join(curve, "\n"))
+	}
 	return strings.Join(summaries, "\n"), nil
 }
+
+// summarizeErrors describes the success and error counts for a metric, along with the number of
+// errors in each class. The caller must hold the lock.
+func (m *Metrics) summarizeErrors(name string) string {
+	successes := int64(len(m.durationsByName[name]))
+	var errors int64
+	classes := []string{}
+	for class, count := range m.errorsByName[name] {
+		errors += count
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	counts := []string{}
+	for _, class := range classes {
+		counts = append(counts, fmt.Sprintf("%s=%d", class, m.errorsByName[name][class]))
+	}
+	return fmt.Sprintf("successes=%d, errors=%d, errorRate=%.2f%% (%s)",
+		successes,
+		errors,
+		100*float64(errors)/float64(successes+errors),
+		strings.Join(counts, ", "))
+}
@@ -0,0 +1,130 @@
+package timer
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestObserve(t *testing.T) {
+	m := NewMetrics()
+	for _, value := range []int64{4, 2, 6} {
+		m.Observe("query [PARTITIONS]", value)
+	}
+	summary, err := m.Summarize()
+	if err != nil {
+		t.Fatalf("Summarize() returned error: %v", err)
+	}
+	want := "query [PARTITIONS]: samples=3, min=2, mean=4.00, max=6"
+	if !strings.Contains(summary, want) {
+		t.Errorf("Summarize() = %q, want it to contain %q", summary, want)
+	}
+}
+
+func TestConcurrentUse(t *testing.T) {
+	const name = "Test.concurrent"
+	const workers = 8
+	const samples = 100
+	m := NewMetrics()
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < samples; i++ {
+				m.Track(time.Now(), name)
+				m.Observe(name+" [VALUES]", int64(i))
+			}
+		}()
+	}
+	wg.Wait()
+	if got := len(m.durationsByName[name]); got != workers*samples {
+		t.Errorf("Track recorded %d samples, want %d", got, workers*samples)
+	}
+	if got := len(m.valuesByName[name+" [VALUES]"]); got != workers*samples {
+		t.Errorf("Observe recorded %d values, want %d", got, workers*samples)
+	}
+}
+
+func TestStepResult(t *testing.T) {
+	const name = "Test.step [10.00qps]"
+	m := NewMetrics()
+	for i := int64(1); i <= 100; i++ {
+		m.durationsByName[name] = append(m.durationsByName[name], i*int64(time.Millisecond))
+	}
+	m.errorsByName[name] = map[string]int64{"Unavailable": 20, "DeadlineExceeded": 5}
+
+	result, err := m.StepResult(name, 10, 2*time.Second)
+	if err != nil {
+		t.Fatalf("StepResult returned error: %v", err)
+	}
+	want := StepResult{
+		OfferedQPS: 10,
+		Throughput: 50,
+		Successes:  100,
+		Errors:     25,
+		Median:     50500 * time.Microsecond,
+		Pct99:      99 * time.Millisecond,
+	}
+	if result != want {
+		t.Errorf("StepResult = %+v, want %+v", result, want)
+	}
+	if got := result.ErrorRate(); got != 0.2 {
+		t.Errorf("ErrorRate() = %v, want 0.2", got)
+	}
+}
+
+func TestStepResultWithoutSuccesses(t *testing.T) {
+	const name = "Test.step [10.00qps]"
+	m := NewMetrics()
+	m.errorsByName[name] = map[string]int64{"Unavailable": 3}
+
+	result, err := m.StepResult(name, 10, time.Second)
+	if err != nil {
+		t.Fatalf("StepResult returned error: %v", err)
+	}
+	want := StepResult{OfferedQPS: 10, Errors: 3}
+	if result != want {
+		t.Errorf("StepResult = %+v, want %+v", result, want)
+	}
+	if got := result.ErrorRate(); got != 1 {
+		t.Errorf("ErrorRate() = %v, want 1", got)
+	}
+	if got := (StepResult{}).ErrorRate(); got != 0 {
+		t.Errorf("ErrorRate() of an empty step = %v, want 0", got)
+	}
+}
+
+func TestRatio(t *testing.T) {
+	m := NewMetrics()
+	m.Ratio("Test.swap [ABORT RATE]", "Test.swap [RETRIES]", "Test.swap [ATTEMPTS]")
+	m.Ratio("Test.swap [RETRY RATE]", "Test.swap [RETRIED]", "Test.swap [TRANSACTIONS]")
+	m.Ratio("Test.idle [ABORT RATE]", "Test.idle [RETRIES]", "Test.idle [ATTEMPTS]")
+	// Five transactions took eight attempts, since two of them were retried three times in total.
+	for _, attempts := range []int64{1, 3, 1, 2, 1} {
+		m.Count("Test.swap [TRANSACTIONS]")
+		m.Add("Test.swap [ATTEMPTS]", attempts)
+		if attempts > 1 {
+			m.Add("Test.swap [RETRIES]", attempts-1)
+			m.Count("Test.swap [RETRIED]")
+		}
+	}
+
+	summary, err := m.Summarize()
+	if err != nil {
+		t.Fatalf("Summarize() returned error: %v", err)
+	}
+	for _, want := range []string{
+		"Test.swap [ABORT RATE]: 37.50% (3/8)",
+		"Test.swap [RETRY RATE]: 40.00% (2/5)",
+	} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("Summarize() = %q, want it to contain %q", summary, want)
+		}
+	}
+	// A ratio without a denominator has nothing to report.
+	if strings.Contains(summary, "Test.idle") {
+		t.Errorf("Summarize() = %q, want no ratio for Test.idle", summary)
+	}
+}
@@ -0,0 +1,56 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// errInsufficientFunds is returned by Backend.Transfer when the account being debited does not
+// have enough balance. Nothing is changed when it is returned.
+var errInsufficientFunds = errors.New("Insufficient funds")
+
+// Transaction is a single row of the ledger's transactions table, independent of the database
+// that stores it.
+type Transaction struct {
+	ID         int64
+	CompanyID  int64
+	FromUserID int64
+	ToUserID   int64
+	Time       time.Time
+}
+
+// Backend provides the ledger operations exercised by the workflows, implemented once per
+// database. Each operation maps onto the closest native primitive of the database, so that a
+// single workflow suite can compare databases on the same logical operations.
+type Backend interface {
+	// Addressable returns whether transactions can be read, changed and deleted by ID alone. The
+	// operations that take a transaction ID fail when it returns false.
+	Addressable() bool
+	// ReadTransaction reads a single transaction by ID. It fails with a NotFound error if there
+	// is no such transaction.
+	ReadTransaction(ctx context.Context, id int64) (Transaction, error)
+	// ReadTransactionRange reads every transaction with an ID from startID (inclusive) to endID
+	// (exclusive).
+	ReadTransactionRange(ctx context.Context, startID int64, endID int64) ([]Transaction, error)
+	// ReadTransactions reads the transactions with the given IDs, skipping any that are missing.
+	ReadTransactions(ctx context.Context, ids []int64) ([]Transaction, error)
+	// ScanTransactions reads up to limit transactions in key order, starting at startID.
+	ScanTransactions(ctx context.Context, startID int64, limit int64) ([]Transaction, error)
+	// WriteTransaction blindly writes a transaction, without reading it first. A zero Time is
+	// replaced by the time of the write.
+	WriteTransaction(ctx context.Context, txn Transaction) error
+	// DeleteTransaction deletes a transaction by ID.
+	DeleteTransaction(ctx context.Context, id int64) error
+	// ReadModifyWriteTransaction atomically reads a transaction and updates it based on what was
+	// read.
+	ReadModifyWriteTransaction(ctx context.Context, id int64) error
+
+	// AccountIDs returns the ID of every account.
+	AccountIDs(ctx context.Context) ([]int64, error)
+	// Transfer moves amount from one account to another. The total balance across every account
+	// is meant to stay the same, to whatever extent the database can guarantee it.
+	Transfer(ctx context.Context, fromID int64, toID int64, amount int64) error
+	// TotalBalance returns the sum of the balances of every account.
+	TotalBalance(ctx context.Context) (int64, error)
+}
@@ -0,0 +1,365 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/bigtable"
+	"github.com/r7wang/gcloud-test/datagen"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// transferMaxAttempts bounds the attempts to credit, or refund, an account whose balance keeps
+	// changing between the read and the write.
+	transferMaxAttempts = 10
+	// transferRefundTimeout bounds the refund of a transfer whose credit failed.
+	transferRefundTimeout = 10 * time.Second
+)
+
+// BackendBigtable implements Backend on top of Cloud Bigtable. Transactions are keyed by a row key
+// strategy and store the time of the transaction as the timestamp of their cells.
+//
+// Transactions are addressed by ID, so every operation that takes an ID fails unless the strategy
+// can build row keys from IDs alone.
+type BackendBigtable struct {
+	client  *bigtable.Client
+	rowKeys datagen.RowKeyStrategy
+}
+
+// NewBackendBigtable returns a new BackendBigtable instance.
+func NewBackendBigtable(client *bigtable.Client, rowKeys datagen.RowKeyStrategy) *BackendBigtable {
+	return &BackendBigtable{client: client, rowKeys: rowKeys}
+}
+
+// Addressable returns whether the row key strategy can build row keys from transaction IDs.
+func (b *BackendBigtable) Addressable() bool {
+	return b.rowKeys.Addressable()
+}
+
+// ReadTransaction reads a single row using ReadRow.
+func (b *BackendBigtable) ReadTransaction(ctx context.Context, id int64) (Transaction, error) {
+	rowKey, err := b.rowKey(id)
+	if err != nil {
+		return Transaction{}, err
+	}
+	table := b.client.Open(datagen.TransactionTableName)
+	row, err := table.ReadRow(ctx, rowKey, bigtable.RowFilter(bigtable.LatestNFilter(1)))
+	if err != nil {
+		return Transaction{}, err
+	}
+	// Bigtable returns an empty row instead of an error for missing rows. Report them the same
+	// way that Spanner does.
+	if len(row) == 0 {
+		return Transaction{}, status.Errorf(codes.NotFound, "transaction %d not found", id)
+	}
+	return b.readTransaction(row)
+}
+
+// ReadTransactionRange reads multiple rows using the row ranges that hold the IDs, which is a
+// single range unless the row keys are salted.
+func (b *BackendBigtable) ReadTransactionRange(ctx context.Context, startID int64, endID int64) ([]Transaction, error) {
+	rows, ok := b.rowKeys.IDRange(startID, endID)
+	if !ok {
+		return nil, b.errNotAddressable()
+	}
+	return b.readTransactions(ctx, rows)
+}
+
+// ReadTransactions reads multiple rows using a row list.
+func (b *BackendBigtable) ReadTransactions(ctx context.Context, ids []int64) ([]Transaction, error) {
+	rowKeys := []string{}
+	for _, id := range ids {
+		rowKey, err := b.rowKey(id)
+		if err != nil {
+			return nil, err
+		}
+		rowKeys = append(rowKeys, rowKey)
+	}
+	return b.readTransactions(ctx, bigtable.RowList(rowKeys))
+}
+
+// ScanTransactions reads multiple rows using an open-ended row range with a row limit.
+func (b *BackendBigtable) ScanTransactions(ctx context.Context, startID int64, limit int64) ([]Transaction, error) {
+	startKey, err := b.rowKey(startID)
+	if err != nil {
+		return nil, err
+	}
+	return b.readTransactions(ctx, bigtable.InfiniteRange(startKey), bigtable.LimitRows(limit))
+}
+
+// WriteTransaction blindly writes a single row using Apply.
+func (b *BackendBigtable) WriteTransaction(ctx context.Context, txn Transaction) error {
+	ts := bigtable.Now()
+	if !txn.Time.IsZero() {
+		ts = bigtable.Time(txn.Time)
+	}
+	mutation := bigtable.NewMutation()
+	mutation.Set(datagen.DefaultColumnFamily, datagen.TransactionCompanyColumn, ts, []byte(datagen.Int64String(txn.CompanyID)))
+	mutation.Set(datagen.DefaultColumnFamily, datagen.TransactionFromUserColumn, ts, []byte(datagen.Int64String(txn.FromUserID)))
+	mutation.Set(datagen.DefaultColumnFamily, datagen.TransactionToUserColumn, ts, []byte(datagen.Int64String(txn.ToUserID)))
+	rowKey := b.rowKeys.Encode(datagen.TransactionKey{
+		ID:         txn.ID,
+		CompanyID:  datagen.Int64String(txn.CompanyID),
+		FromUserID: datagen.Int64String(txn.FromUserID),
+		Time:       ts.Time(),
+	})
+	table := b.client.Open(datagen.TransactionTableName)
+	return table.Apply(ctx, rowKey, mutation)
+}
+
+// DeleteTransaction deletes a single row using Apply.
+func (b *BackendBigtable) DeleteTransaction(ctx context.Context, id int64) error {
+	rowKey, err := b.rowKey(id)
+	if err != nil {
+		return err
+	}
+	mutation := bigtable.NewMutation()
+	mutation.DeleteRow()
+	table := b.client.Open(datagen.TransactionTableName)
+	return table.Apply(ctx, rowKey, mutation)
+}
+
+// ReadModifyWriteTransaction increments the revision counter of a transaction using
+// ApplyReadModifyWrite.
+//
+// Bigtable only supports appends and increments as atomic read-modify-write operations. Neither
+// can swap the sender and receiver the way that Spanner does, and appending to either of them
+// would leave an invalid ID behind, so a separate counter is used instead.
+func (b *BackendBigtable) ReadModifyWriteTransaction(ctx context.Context, id int64) error {
+	rowKey, err := b.rowKey(id)
+	if err != nil {
+		return err
+	}
+	rw := bigtable.NewReadModifyWrite()
+	rw.Increment(datagen.DefaultColumnFamily, datagen.TransactionRevisionColumn, 1)
+	table := b.client.Open(datagen.TransactionTableName)
+	_, err = table.ApplyReadModifyWrite(ctx, rowKey, rw)
+	return err
+}
+
+// AccountIDs reads the key of every account using a full scan.
+func (b *BackendBigtable) AccountIDs(ctx context.Context) ([]int64, error) {
+	table := b.client.Open(datagen.AccountTableName)
+	ids := []int64{}
+	var parseErr error
+	err := table.ReadRows(ctx, bigtable.InfiniteRange(""), func(row bigtable.Row) bool {
+		id, err := strconv.ParseInt(row.Key(), 10, 64)
+		if err != nil {
+			parseErr = err
+			return false
+		}
+		ids = append(ids, id)
+		return true
+	}, bigtable.RowFilter(bigtable.ChainFilters(bigtable.CellsPerRowLimitFilter(1), bigtable.StripValueFilter())))
+	if err != nil {
+		return nil, err
+	}
+	return ids, parseErr
+}
+
+// Transfer debits one account and then credits the other, each with a conditional mutation that
+// only applies if the balance has not changed since it was read.
+//
+// Bigtable cannot change two rows atomically, so the total balance is off by amount between the
+// debit and the credit. A concurrent change to the debited account fails the transfer with Aborted
+// before anything is changed, while a concurrent change to the credited account retries the credit
+// up to transferMaxAttempts times. If the credit still fails, the debited account is refunded the
+// same way and the transfer fails with Aborted. Only if the refund fails as well is amount lost for
+// good.
+func (b *BackendBigtable) Transfer(ctx context.Context, fromID int64, toID int64, amount int64) error {
+	fromBalance, err := b.readBalance(ctx, fromID)
+	if err != nil {
+		return err
+	}
+	if fromBalance < amount {
+		return errInsufficientFunds
+	}
+	matched, err := b.swapBalance(ctx, fromID, fromBalance, fromBalance-amount)
+	if err != nil {
+		return err
+	}
+	if !matched {
+		return status.Errorf(codes.Aborted, "account %d changed before it was debited", fromID)
+	}
+
+	creditErr := b.addBalance(ctx, toID, amount)
+	if creditErr == nil {
+		return nil
+	}
+	// The context of the transfer may be what failed the credit, so the refund gets its own.
+	refundCtx, cancel := context.WithTimeout(context.Background(), transferRefundTimeout)
+	defer cancel()
+	if err := b.addBalance(refundCtx, fromID, amount); err != nil {
+		return status.Errorf(
+			codes.Aborted,
+			"account %d was not credited (%v) and account %d was not refunded (%v), %d is lost",
+			toID, creditErr, fromID, err, amount)
+	}
+	return status.Errorf(codes.Aborted, "account %d was not credited, account %d was refunded: %v", toID, fromID, creditErr)
+}
+
+// TotalBalance sums every balance using a full scan. Bigtable does not read from a consistent
+// snapshot, so the sum includes any transfer that is only partially applied.
+func (b *BackendBigtable) TotalBalance(ctx context.Context) (int64, error) {
+	table := b.client.Open(datagen.AccountTableName)
+	var total int64
+	var parseErr error
+	err := table.ReadRows(ctx, bigtable.InfiniteRange(""), func(row bigtable.Row) bool {
+		balance, err := b.parseBalance(row)
+		if err != nil {
+			parseErr = err
+			return false
+		}
+		total += balance
+		return true
+	}, bigtable.RowFilter(b.balanceFilter()))
+	if err != nil {
+		return 0, err
+	}
+	return total, parseErr
+}
+
+func (b *BackendBigtable) readBalance(ctx context.Context, id int64) (int64, error) {
+	table := b.client.Open(datagen.AccountTableName)
+	row, err := table.ReadRow(ctx, datagen.Int64String(id), bigtable.RowFilter(b.balanceFilter()))
+	if err != nil {
+		return 0, err
+	}
+	if len(row) == 0 {
+		return 0, status.Errorf(codes.NotFound, "account %d not found", id)
+	}
+	return b.parseBalance(row)
+}
+
+// addBalance adds amount to the balance of an account. It retries whenever the balance changes
+// between the read and the write, and fails with Aborted after transferMaxAttempts attempts.
+func (b *BackendBigtable) addBalance(ctx context.Context, id int64, amount int64) error {
+	for i := 0; i < transferMaxAttempts; i++ {
+		balance, err := b.readBalance(ctx, id)
+		if err != nil {
+			return err
+		}
+		matched, err := b.swapBalance(ctx, id, balance, balance+amount)
+		if err != nil {
+			return err
+		}
+		if matched {
+			return nil
+		}
+	}
+	return status.Errorf(codes.Aborted, "account %d changed on each of %d attempts", id, transferMaxAttempts)
+}
+
+// swapBalance replaces the balance of an account, but only if it still matches the balance that
+// was read. It returns whether the balance was replaced.
+func (b *BackendBigtable) swapBalance(ctx context.Context, id int64, oldBalance int64, newBalance int64) (bool, error) {
+	filter := bigtable.ChainFilters(
+		b.balanceFilter(),
+		bigtable.ValueFilter(fmt.Sprintf("^%s$", datagen.Int64String(oldBalance))))
+	mutation := bigtable.NewMutation()
+	mutation.Set(
+		datagen.DefaultColumnFamily,
+		datagen.AccountBalanceColumn,
+		bigtable.Now(),
+		[]byte(datagen.Int64String(newBalance)))
+	var matched bool
+	table := b.client.Open(datagen.AccountTableName)
+	err := table.Apply(
+		ctx,
+		datagen.Int64String(id),
+		bigtable.NewCondMutation(filter, mutation, nil),
+		bigtable.GetCondMutationResult(&matched))
+	return matched, err
+}
+
+// balanceFilter reduces an account to the latest version of its balance.
+func (b *BackendBigtable) balanceFilter() bigtable.Filter {
+	return bigtable.ChainFilters(
+		bigtable.ColumnFilter(fmt.Sprintf("^%s$", datagen.AccountBalanceColumn)),
+		bigtable.LatestNFilter(1))
+}
+
+func (b *BackendBigtable) parseBalance(row bigtable.Row) (int64, error) {
+	cells := row[datagen.DefaultColumnFamily]
+	if len(cells) == 0 {
+		return 0, fmt.Errorf("Account %s has no balance", row.Key())
+	}
+	return strconv.ParseInt(string(cells[0].Value), 10, 64)
+}
+
+func (b *BackendBigtable) readTransactions(
+	ctx context.Context,
+	rowSet bigtable.RowSet,
+	opts ...bigtable.ReadOption,
+) ([]Transaction, error) {
+
+	txns := []Transaction{}
+	var decodeErr error
+	table := b.client.Open(datagen.TransactionTableName)
+	opts = append(opts, bigtable.RowFilter(bigtable.LatestNFilter(1)))
+	err := table.ReadRows(ctx, rowSet, func(row bigtable.Row) bool {
+		txn, err := b.readTransaction(row)
+		if err != nil {
+			decodeErr = err
+			return false
+		}
+		txns = append(txns, txn)
+		return true
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+	return txns, nil
+}
+
+// rowKey returns the row key of a transaction from its ID.
+func (b *BackendBigtable) rowKey(id int64) (string, error) {
+	if !b.rowKeys.Addressable() {
+		return "", b.errNotAddressable()
+	}
+	return b.rowKeys.Encode(datagen.TransactionKey{ID: id}), nil
+}
+
+func (b *BackendBigtable) errNotAddressable() error {
+	return fmt.Errorf("Row key strategy %s cannot build row keys from transaction IDs", b.rowKeys.Name())
+}
+
+func (b *BackendBigtable) readTransaction(row bigtable.Row) (Transaction, error) {
+	key, err := b.rowKeys.Decode(row.Key())
+	if err != nil {
+		return Transaction{}, err
+	}
+	txn := Transaction{ID: key.ID}
+	for _, cell := range row[datagen.DefaultColumnFamily] {
+		switch cell.Column {
+		case fmt.Sprintf("%s:%s", datagen.DefaultColumnFamily, datagen.TransactionCompanyColumn):
+			txn.CompanyID = parseLeadingInt64(cell.Value)
+		case fmt.Sprintf("%s:%s", datagen.DefaultColumnFamily, datagen.TransactionFromUserColumn):
+			txn.FromUserID = parseLeadingInt64(cell.Value)
+			txn.Time = cell.Timestamp.Time()
+		case fmt.Sprintf("%s:%s", datagen.DefaultColumnFamily, datagen.TransactionToUserColumn):
+			txn.ToUserID = parseLeadingInt64(cell.Value)
+		}
+	}
+	return txn, nil
+}
+
+// parseLeadingInt64 parses the decimal digits at the start of a value. OLTPBigtable.atomicAppend
+// appends a suffix to the receiver of a transaction, so anything after the digits is ignored.
+func parseLeadingInt64(value []byte) int64 {
+	var parsed int64
+	for _, b := range value {
+		if b < '0' || b > '9' {
+			break
+		}
+		parsed = parsed*10 + int64(b-'0')
+	}
+	return parsed
+}
@@ -0,0 +1,110 @@
+package workflow
+
+import (
+	"context"
+	"testing"
+
+	"cloud.google.com/go/bigtable"
+	"cloud.google.com/go/bigtable/bttest"
+	"github.com/r7wang/gcloud-test/datagen"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newTestBigtable returns a client of an in-memory Bigtable server that holds the empty ledger
+// tables. The server is shut down when the test finishes.
+func newTestBigtable(t *testing.T) *bigtable.Client {
+	t.Helper()
+	ctx := context.Background()
+	srv, err := bttest.NewServer("localhost:0")
+	if err != nil {
+		t.Fatalf("bttest.NewServer() returned error: %v", err)
+	}
+	t.Cleanup(srv.Close)
+	conn, err := grpc.Dial(srv.Addr, grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("grpc.Dial() returned error: %v", err)
+	}
+	admin, err := bigtable.NewAdminClient(ctx, "project", "instance", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("bigtable.NewAdminClient() returned error: %v", err)
+	}
+	if err := datagen.NewSchemaBigtable(ctx, admin).CreateTables(); err != nil {
+		t.Fatalf("CreateTables() returned error: %v", err)
+	}
+	client, err := bigtable.NewClient(ctx, "project", "instance", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("bigtable.NewClient() returned error: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return client
+}
+
+// setBalance writes the balance of an account as the given raw value.
+func setBalance(t *testing.T, client *bigtable.Client, id int64, value string) {
+	t.Helper()
+	mutation := bigtable.NewMutation()
+	mutation.Set(datagen.DefaultColumnFamily, datagen.AccountBalanceColumn, bigtable.Now(), []byte(value))
+	table := client.Open(datagen.AccountTableName)
+	if err := table.Apply(context.Background(), datagen.Int64String(id), mutation); err != nil {
+		t.Fatalf("Apply() returned error: %v", err)
+	}
+}
+
+func TestBackendBigtableTransfer(t *testing.T) {
+	tests := []struct {
+		name string
+		// toBalance is the raw balance of the credited account, or empty if it does not exist.
+		toBalance   string
+		wantCode    codes.Code
+		wantBalance int64
+	}{
+		{name: "credited", toBalance: "500", wantCode: codes.OK, wantBalance: 900},
+		{name: "missing", toBalance: "", wantCode: codes.Aborted, wantBalance: 1000},
+		// The balance parses as 500, but never matches the filter that only applies the credit
+		// if the balance is still 500.
+		{name: "contended", toBalance: "0500", wantCode: codes.Aborted, wantBalance: 1000},
+	}
+	for _, tt := range tests {
+		ctx := context.Background()
+		client := newTestBigtable(t)
+		backend := NewBackendBigtable(client, nil)
+		setBalance(t, client, 1, "1000")
+		if tt.toBalance != "" {
+			setBalance(t, client, 2, tt.toBalance)
+		}
+
+		err := backend.Transfer(ctx, 1, 2, 100)
+		if status.Code(err) != tt.wantCode {
+			t.Errorf("%s: Transfer() error = %v, want code %v", tt.name, err, tt.wantCode)
+		}
+		balance, err := backend.readBalance(ctx, 1)
+		if err != nil {
+			t.Fatalf("%s: readBalance() returned error: %v", tt.name, err)
+		}
+		if balance != tt.wantBalance {
+			t.Errorf("%s: debited balance = %d, want %d", tt.name, balance, tt.wantBalance)
+		}
+	}
+}
+
+func TestBackendBigtableTransferInsufficientFunds(t *testing.T) {
+	ctx := context.Background()
+	client := newTestBigtable(t)
+	backend := NewBackendBigtable(client, nil)
+	setBalance(t, client, 1, "50")
+	setBalance(t, client, 2, "500")
+
+	if err := backend.Transfer(ctx, 1, 2, 100); err != errInsufficientFunds {
+		t.Errorf("Transfer() error = %v, want %v", err, errInsufficientFunds)
+	}
+	total, err := backend.TotalBalance(ctx)
+	if err != nil {
+		t.Fatalf("TotalBalance() returned error: %v", err)
+	}
+	if total != 550 {
+		t.Errorf("TotalBalance() = %d, want 550", total)
+	}
+}
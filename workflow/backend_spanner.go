@@ -0,0 +1,239 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/r7wang/gcloud-test/datagen"
+	"google.golang.org/api/iterator"
+)
+
+// transactionColumnsSpanner lists every column of the transactions table, in the order that
+// readTransaction expects.
+var transactionColumnsSpanner = []string{
+	"Id",
+	datagen.TransactionCompanyColumn,
+	datagen.TransactionFromUserColumn,
+	datagen.TransactionToUserColumn,
+	"Time",
+}
+
+// BackendSpanner implements Backend on top of Cloud Spanner.
+type BackendSpanner struct {
+	client *spanner.Client
+	schema datagen.SpannerSchema
+}
+
+// NewBackendSpanner returns a new BackendSpanner instance for a database with the given schema
+// variant.
+func NewBackendSpanner(client *spanner.Client, schema datagen.SpannerSchema) *BackendSpanner {
+	return &BackendSpanner{client: client, schema: schema}
+}
+
+// Addressable always returns true, since every schema variant can look up transactions by ID.
+func (b *BackendSpanner) Addressable() bool {
+	return true
+}
+
+// ReadTransaction reads a single row using a Read, through the ID index if the schema variant has
+// one.
+func (b *BackendSpanner) ReadTransaction(ctx context.Context, id int64) (Transaction, error) {
+	row, err := readTransactionByID(ctx, b.client.Single(), b.schema, id, transactionColumnsSpanner)
+	if err != nil {
+		return Transaction{}, err
+	}
+	return b.readTransaction(row)
+}
+
+// ReadTransactionRange reads multiple rows using a sequential Read, through the ID index if the
+// schema variant has one.
+func (b *BackendSpanner) ReadTransactionRange(ctx context.Context, startID int64, endID int64) ([]Transaction, error) {
+	keys := spanner.KeyRange{
+		Start: spanner.Key{startID},
+		End:   spanner.Key{endID},
+	}
+	iter := readTransactionsByID(ctx, b.client.Single(), b.schema, keys, transactionColumnsSpanner, 0)
+	defer iter.Stop()
+	return b.readTransactions(iter)
+}
+
+// ReadTransactions reads multiple rows using a Query against the unique ID index, if the schema
+// variant has one.
+func (b *BackendSpanner) ReadTransactions(ctx context.Context, ids []int64) ([]Transaction, error) {
+	stmt := spanner.Statement{
+		SQL: fmt.Sprintf(`SELECT t.Id, t.CompanyId, t.FromUserId, t.ToUserId, t.Time
+				FROM Transactions%s t
+				WHERE t.Id IN UNNEST(@keys)`, b.schema.TransactionIDHint()),
+		Params: map[string]interface{}{
+			"keys": ids,
+		},
+	}
+	iter := b.client.Single().Query(ctx, stmt)
+	defer iter.Stop()
+	return b.readTransactions(iter)
+}
+
+// ScanTransactions reads multiple rows using an open-ended Read with a row limit, through the ID
+// index if the schema variant has one.
+func (b *BackendSpanner) ScanTransactions(ctx context.Context, startID int64, limit int64) ([]Transaction, error) {
+	keys := spanner.KeyRange{
+		Start: spanner.Key{startID},
+		End:   spanner.Key{},
+		Kind:  spanner.ClosedClosed,
+	}
+	iter := readTransactionsByID(ctx, b.client.Single(), b.schema, keys, transactionColumnsSpanner, int(limit))
+	defer iter.Stop()
+	return b.readTransactions(iter)
+}
+
+// WriteTransaction blindly inserts a single row using Apply.
+func (b *BackendSpanner) WriteTransaction(ctx context.Context, txn Transaction) error {
+	var ts interface{} = txn.Time
+	if txn.Time.IsZero() {
+		ts = spanner.CommitTimestamp
+	}
+	mutation := spanner.InsertMap(datagen.TransactionTableName, map[string]interface{}{
+		"id":         txn.ID,
+		"companyId":  txn.CompanyID,
+		"fromUserId": txn.FromUserID,
+		"toUserId":   txn.ToUserID,
+		"time":       ts,
+	})
+	_, err := b.client.Apply(ctx, []*spanner.Mutation{mutation})
+	return err
+}
+
+// DeleteTransaction deletes a single row using Apply, or within a ReadWriteTransaction that first
+// looks up its primary key if the ID is not the primary key.
+func (b *BackendSpanner) DeleteTransaction(ctx context.Context, id int64) error {
+	return deleteTransactionByID(ctx, b.client, b.schema, id)
+}
+
+// ReadModifyWriteTransaction increments the revision counter of a transaction within a
+// ReadWriteTransaction, the same as the Bigtable backend. The sender and the time can be part of
+// the primary key, so the revision is the only column that changes, and the row is updated by its
+// full primary key.
+func (b *BackendSpanner) ReadModifyWriteTransaction(ctx context.Context, id int64) error {
+	_, err := b.client.ReadWriteTransaction(ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+		columns := append(append([]string{}, transactionKeyColumnsSpanner...), datagen.TransactionRevisionColumn)
+		row, err := readTransactionByID(ctx, txn, b.schema, id, columns)
+		if err != nil {
+			return err
+		}
+		var fromUserID int64
+		var ts time.Time
+		var revision spanner.NullInt64
+		if err := row.Columns(&id, &fromUserID, &ts, &revision); err != nil {
+			return err
+		}
+		key := b.schema.TransactionKey(id, fromUserID, ts)
+		mutation := transactionUpdate(
+			b.schema,
+			key,
+			[]string{datagen.TransactionRevisionColumn},
+			[]interface{}{revision.Int64 + 1})
+		return txn.BufferWrite([]*spanner.Mutation{mutation})
+	})
+	return err
+}
+
+// AccountIDs reads the key of every account using a full Read.
+func (b *BackendSpanner) AccountIDs(ctx context.Context) ([]int64, error) {
+	iter := b.client.Single().Read(ctx, datagen.AccountTableName, spanner.AllKeys(), []string{"Id"})
+	defer iter.Stop()
+	ids := []int64{}
+	var id int64
+	for {
+		row, err := iter.Next()
+		if err != nil {
+			if err == iterator.Done {
+				break
+			}
+			return nil, err
+		}
+		if err := row.Columns(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// Transfer reads both balances and writes them back within a single ReadWriteTransaction, so the
+// total balance never changes.
+func (b *BackendSpanner) Transfer(ctx context.Context, fromID int64, toID int64, amount int64) error {
+	_, err := b.client.ReadWriteTransaction(ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+		fromBalance, err := b.readBalance(ctx, txn, fromID)
+		if err != nil {
+			return err
+		}
+		if fromBalance < amount {
+			return errInsufficientFunds
+		}
+		toBalance, err := b.readBalance(ctx, txn, toID)
+		if err != nil {
+			return err
+		}
+		return txn.BufferWrite([]*spanner.Mutation{
+			spanner.Update(datagen.AccountTableName, []string{"Id", datagen.AccountBalanceColumn}, []interface{}{fromID, fromBalance - amount}),
+			spanner.Update(datagen.AccountTableName, []string{"Id", datagen.AccountBalanceColumn}, []interface{}{toID, toBalance + amount}),
+		})
+	})
+	return err
+}
+
+// TotalBalance sums every balance using a single Query, which reads from one consistent snapshot.
+func (b *BackendSpanner) TotalBalance(ctx context.Context) (int64, error) {
+	stmt := spanner.Statement{
+		SQL: `SELECT SUM(a.Balance)
+				FROM Accounts a`,
+	}
+	iter := b.client.Single().Query(ctx, stmt)
+	defer iter.Stop()
+	row, err := iter.Next()
+	if err != nil {
+		return 0, err
+	}
+	var total spanner.NullInt64
+	if err := row.Columns(&total); err != nil {
+		return 0, err
+	}
+	return total.Int64, nil
+}
+
+func (b *BackendSpanner) readBalance(ctx context.Context, txn *spanner.ReadWriteTransaction, id int64) (int64, error) {
+	row, err := txn.ReadRow(ctx, datagen.AccountTableName, spanner.Key{id}, []string{datagen.AccountBalanceColumn})
+	if err != nil {
+		return 0, err
+	}
+	var balance int64
+	err = row.Columns(&balance)
+	return balance, err
+}
+
+func (b *BackendSpanner) readTransaction(row *spanner.Row) (Transaction, error) {
+	var txn Transaction
+	err := row.Columns(&txn.ID, &txn.CompanyID, &txn.FromUserID, &txn.ToUserID, &txn.Time)
+	return txn, err
+}
+
+func (b *BackendSpanner) readTransactions(iter *spanner.RowIterator) ([]Transaction, error) {
+	txns := []Transaction{}
+	for {
+		row, err := iter.Next()
+		if err != nil {
+			if err == iterator.Done {
+				break
+			}
+			return nil, err
+		}
+		txn, err := b.readTransaction(row)
+		if err != nil {
+			return nil, err
+		}
+		txns = append(txns, txn)
+	}
+	return txns, nil
+}
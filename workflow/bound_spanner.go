@@ -0,0 +1,54 @@
+package workflow
+
+import (
+	"time"
+
+	"cloud.google.com/go/spanner"
+)
+
+// readBound is a named timestamp bound for single-use Spanner reads. The name is appended to the
+// metric name of each test that reads with the bound.
+type readBound struct {
+	name  string
+	bound func() spanner.TimestampBound
+}
+
+// staleReadBounds returns a bound for each way of reading data that is the given age, or no bounds
+// if the age is not positive.
+//
+// ReadTimestamp is computed when each read starts, so that every sample reads data of the same age
+// instead of progressively older data.
+func staleReadBounds(staleness time.Duration) []readBound {
+	if staleness <= 0 {
+		return nil
+	}
+	return []readBound{
+		{
+			name: "exactStaleness",
+			bound: func() spanner.TimestampBound {
+				return spanner.ExactStaleness(staleness)
+			},
+		},
+		{
+			name: "maxStaleness",
+			bound: func() spanner.TimestampBound {
+				return spanner.MaxStaleness(staleness)
+			},
+		},
+		{
+			name: "readTimestamp",
+			bound: func() spanner.TimestampBound {
+				return spanner.ReadTimestamp(time.Now().Add(-staleness))
+			},
+		},
+	}
+}
+
+// singleUse returns a single-use read-only transaction, with the given bound if there is one.
+// Otherwise, the transaction uses the default strong bound.
+func singleUse(client *spanner.Client, bound *readBound) *spanner.ReadOnlyTransaction {
+	if bound == nil {
+		return client.Single()
+	}
+	return client.Single().WithTimestampBound(bound.bound())
+}
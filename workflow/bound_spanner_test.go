@@ -0,0 +1,46 @@
+package workflow
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+)
+
+func TestStaleReadBounds(t *testing.T) {
+	const staleness = 15 * time.Second
+	bounds := staleReadBounds(staleness)
+	names := []string{}
+	for _, bound := range bounds {
+		names = append(names, bound.name)
+	}
+	if strings.Join(names, ",") != "exactStaleness,maxStaleness,readTimestamp" {
+		t.Fatalf("staleReadBounds(%v) = %v, want exactStaleness, maxStaleness and readTimestamp", staleness, names)
+	}
+	if got, want := bounds[0].bound(), spanner.ExactStaleness(staleness); got != want {
+		t.Errorf("exactStaleness bound = %v, want %v", got, want)
+	}
+	if got, want := bounds[1].bound(), spanner.MaxStaleness(staleness); got != want {
+		t.Errorf("maxStaleness bound = %v, want %v", got, want)
+	}
+
+	// The read timestamp is computed when each read starts, rather than once for every read.
+	first := bounds[2].bound()
+	time.Sleep(time.Millisecond)
+	second := bounds[2].bound()
+	if !strings.HasPrefix(first.String(), "(readTimestamp: ") {
+		t.Errorf("readTimestamp bound = %v, want a read timestamp", first)
+	}
+	if first == second {
+		t.Errorf("readTimestamp bound = %v for two reads, want a timestamp per read", first)
+	}
+}
+
+func TestStaleReadBoundsDisabled(t *testing.T) {
+	for _, staleness := range []time.Duration{0, -time.Second} {
+		if bounds := staleReadBounds(staleness); len(bounds) != 0 {
+			t.Errorf("staleReadBounds(%v) returned %d bounds, want none", staleness, len(bounds))
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package workflow
+
+import (
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/r7wang/gcloud-test/datagen"
+)
+
+// bulkDeleteInterval is the amount by which the cutoff of deleteBefore advances with each sample,
+// so that every sample deletes roughly a month of transactions.
+const bulkDeleteInterval = 30 * 24 * time.Hour
+
+// deleteUserMetricName names the test that deletes a user along with their transactions.
+const deleteUserMetricName = "Bulk.deleteUser"
+
+// bulkConfig returns the configuration that the bulk change tests run with as part of the default
+// test sequence. Each sample changes a large set of rows, so the tests run a small, fixed number
+// of samples one at a time.
+func bulkConfig(config Config) Config {
+	config.NumSamples = config.BulkSamples
+	config.Duration = 0
+	config.Concurrency = 1
+	config.TargetQPS = 0
+	config.StepQPS = 0
+	return config
+}
+
+// rowsMetricName names the counter for the number of rows affected by a bulk change test.
+func rowsMetricName(metricName string) string {
+	return fmt.Sprintf("%s [ROWS]", metricName)
+}
+
+// distinctPair picks two different indexes out of n, so that a test that moves rows from one
+// entity to another never picks the same entity twice and leaves the rows unchanged.
+func distinctPair(r *rand.Rand, n int) (int, int, error) {
+	if n < 2 {
+		return 0, 0, fmt.Errorf("Need at least two rows to pick a distinct pair, found %d", n)
+	}
+	from := r.Intn(n)
+	to := r.Intn(n - 1)
+	if to >= from {
+		to++
+	}
+	return from, to, nil
+}
+
+// cutoff hands out deletion cutoffs that start at the earliest generated transaction and advance
+// by bulkDeleteInterval every time.
+type cutoff struct {
+	count int64
+}
+
+// next returns the next cutoff.
+func (c *cutoff) next() time.Time {
+	n := atomic.AddInt64(&c.count, 1)
+	return time.Unix(datagen.TransactionMinTime, 0).Add(time.Duration(n) * bulkDeleteInterval)
+}
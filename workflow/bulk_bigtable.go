@@ -0,0 +1,246 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigtable"
+	"github.com/r7wang/gcloud-test/datagen"
+	"github.com/r7wang/gcloud-test/timer"
+)
+
+// bulkBatchSize is the number of rows changed by each ApplyBulk call. Bigtable limits the number
+// of mutations in a single request, so large changes are split into batches.
+const bulkBatchSize = 10000
+
+// BulkBigtable defines operations to exercise large, set-based changes. Bigtable has no
+// equivalent of Partitioned DML, so each change scans for the affected rows and then changes them
+// using ApplyBulk.
+//
+// Every test is destructive, so the suite is only part of the default test sequence when
+// BulkSamples is positive.
+type BulkBigtable struct {
+	suiteBase
+	client    *bigtable.Client
+	companies *rowKeyStore
+	cutoff    *cutoff
+}
+
+// NewBulkBigtable returns a new BulkBigtable instance.
+func NewBulkBigtable(
+	ctx context.Context,
+	client *bigtable.Client,
+	metrics *timer.Metrics,
+	config Config,
+) *BulkBigtable {
+
+	return &BulkBigtable{
+		suiteBase: newSuiteBase(ctx, metrics, config),
+		client:    client,
+		companies: &rowKeyStore{},
+		cutoff:    &cutoff{},
+	}
+}
+
+// Run sequentially executes all of the test workflows, with BulkSamples samples each.
+func (wf *BulkBigtable) Run() error {
+	return runTests(wf.withConfig(bulkConfig(wf.config)).tests(), wf.config)
+}
+
+// Tests returns the name of every test workflow.
+func (wf *BulkBigtable) Tests() []string {
+	return testNames(wf.tests())
+}
+
+// RunTest executes a single test workflow with the given configuration.
+func (wf *BulkBigtable) RunTest(name string, config Config) error {
+	return runNamedTest(wf.withConfig(config).tests(), name)
+}
+
+// withConfig returns a copy of the workflow that runs with the given configuration.
+func (wf *BulkBigtable) withConfig(config Config) *BulkBigtable {
+	step := *wf
+	step.suiteBase = wf.suiteBase.withConfig(config)
+	return &step
+}
+
+func (wf *BulkBigtable) tests() []test {
+	return []test{
+		prepared(wf.loadCompanies, wf.runner.test(wf.retagCompany, "Bulk.retagCompany")),
+		wf.runner.test(wf.deleteBefore, "Bulk.deleteBefore"),
+	}
+}
+
+// Move every transaction of one company over to another company.
+//
+// When the row key strategy promotes the company into the row key, a transaction cannot keep its
+// row, so it is moved to the row key of its new company instead. Otherwise, only the company cell
+// is rewritten.
+func (wf *BulkBigtable) retagCompany(ctx context.Context, r *rand.Rand) error {
+	fromIdx, toIdx, err := distinctPair(r, len(wf.companies.keys))
+	if err != nil {
+		return err
+	}
+	fromCompanyID := wf.companies.keys[fromIdx]
+	toCompanyID := wf.companies.keys[toIdx]
+	filter := bigtable.ChainFilters(
+		bigtable.ColumnFilter(fmt.Sprintf("^%s$", datagen.TransactionCompanyColumn)),
+		bigtable.LatestNFilter(1),
+		bigtable.ValueFilter(fmt.Sprintf("^%s$", fromCompanyID)),
+		bigtable.StripValueFilter())
+	if keysCompany(wf.config.RowKeys) {
+		return wf.scanAndApply(ctx, filter, wf.moveToCompany(toCompanyID), "Bulk.retagCompany")
+	}
+	return wf.scanAndApply(ctx, filter, applyEach(func() *bigtable.Mutation {
+		mutation := bigtable.NewMutation()
+		mutation.Set(datagen.DefaultColumnFamily, datagen.TransactionCompanyColumn, bigtable.Now(), []byte(toCompanyID))
+		return mutation
+	}), "Bulk.retagCompany")
+}
+
+// Delete every transaction older than a cutoff. The cutoff advances with every sample.
+func (wf *BulkBigtable) deleteBefore(ctx context.Context, r *rand.Rand) error {
+	filter := bigtable.ChainFilters(
+		bigtable.ColumnFilter(fmt.Sprintf("^%s$", datagen.TransactionFromUserColumn)),
+		bigtable.LatestNFilter(1),
+		bigtable.TimestampRangeFilterMicros(0, bigtable.Time(wf.cutoff.next())),
+		bigtable.StripValueFilter())
+	return wf.scanAndApply(ctx, filter, applyEach(func() *bigtable.Mutation {
+		mutation := bigtable.NewMutation()
+		mutation.DeleteRow()
+		return mutation
+	}), "Bulk.deleteBefore")
+}
+
+// bulkChange changes a batch of transactions, given their row keys.
+type bulkChange func(ctx context.Context, table *bigtable.Table, rowKeys []string) error
+
+// scanAndApply scans every transaction that passes the filter, then changes them in batches. It
+// records the wall time of the scan, of the changes, and of both together, along with the number
+// of rows affected.
+func (wf *BulkBigtable) scanAndApply(
+	ctx context.Context,
+	filter bigtable.Filter,
+	change bulkChange,
+	metricName string,
+) error {
+
+	table := wf.client.Open(datagen.TransactionTableName)
+	start := time.Now()
+	rowKeys := []string{}
+	err := table.ReadRows(ctx, bigtable.InfiniteRange(""), func(row bigtable.Row) bool {
+		rowKeys = append(rowKeys, row.Key())
+		return true
+	}, bigtable.RowFilter(filter))
+	if err != nil {
+		return err
+	}
+	wf.metrics.Elapsed(start, fmt.Sprintf("%s.Scan", metricName))
+
+	applyStart := time.Now()
+	for batchStart := 0; batchStart < len(rowKeys); batchStart += bulkBatchSize {
+		batchEnd := batchStart + bulkBatchSize
+		if batchEnd > len(rowKeys) {
+			batchEnd = len(rowKeys)
+		}
+		if err := change(ctx, table, rowKeys[batchStart:batchEnd]); err != nil {
+			return err
+		}
+	}
+	wf.metrics.Elapsed(applyStart, fmt.Sprintf("%s.ApplyBulk", metricName))
+	wf.metrics.Elapsed(start, metricName)
+	wf.metrics.Add(rowsMetricName(metricName), int64(len(rowKeys)))
+	return nil
+}
+
+// applyEach returns a change that applies a new mutation to every row of a batch.
+func applyEach(newMutation func() *bigtable.Mutation) bulkChange {
+	return func(ctx context.Context, table *bigtable.Table, rowKeys []string) error {
+		mutations := []*bigtable.Mutation{}
+		for range rowKeys {
+			mutations = append(mutations, newMutation())
+		}
+		return applyBulk(ctx, table, rowKeys, mutations)
+	}
+}
+
+// moveToCompany returns a change that moves every row of a batch to the row key of the same
+// transaction under a new company. The latest version of each cell is copied with its timestamp,
+// which holds the time of the transaction, and the old row is deleted once every copy is written.
+//
+// Bigtable cannot change two rows atomically, so a transaction is briefly stored under both keys.
+func (wf *BulkBigtable) moveToCompany(companyID string) bulkChange {
+	companyColumn := fmt.Sprintf("%s:%s", datagen.DefaultColumnFamily, datagen.TransactionCompanyColumn)
+	return func(ctx context.Context, table *bigtable.Table, rowKeys []string) error {
+		newRowKeys := []string{}
+		writes := []*bigtable.Mutation{}
+		oldRowKeys := []string{}
+		var decodeErr error
+		err := table.ReadRows(ctx, bigtable.RowList(rowKeys), func(row bigtable.Row) bool {
+			key, err := wf.config.RowKeys.Decode(row.Key())
+			if err != nil {
+				decodeErr = err
+				return false
+			}
+			key.CompanyID = companyID
+			mutation := bigtable.NewMutation()
+			for _, cell := range row[datagen.DefaultColumnFamily] {
+				value := cell.Value
+				if cell.Column == companyColumn {
+					value = []byte(companyID)
+				}
+				column := strings.TrimPrefix(cell.Column, datagen.DefaultColumnFamily+":")
+				mutation.Set(datagen.DefaultColumnFamily, column, cell.Timestamp, value)
+			}
+			newRowKey := wf.config.RowKeys.Encode(key)
+			newRowKeys = append(newRowKeys, newRowKey)
+			writes = append(writes, mutation)
+			if newRowKey != row.Key() {
+				oldRowKeys = append(oldRowKeys, row.Key())
+			}
+			return true
+		}, bigtable.RowFilter(bigtable.LatestNFilter(1)))
+		if err != nil {
+			return err
+		}
+		if decodeErr != nil {
+			return decodeErr
+		}
+		if err := applyBulk(ctx, table, newRowKeys, writes); err != nil {
+			return err
+		}
+		deletes := []*bigtable.Mutation{}
+		for range oldRowKeys {
+			mutation := bigtable.NewMutation()
+			mutation.DeleteRow()
+			deletes = append(deletes, mutation)
+		}
+		return applyBulk(ctx, table, oldRowKeys, deletes)
+	}
+}
+
+// applyBulk applies one mutation to each row with a single ApplyBulk call, failing if any of them
+// fails.
+func applyBulk(ctx context.Context, table *bigtable.Table, rowKeys []string, mutations []*bigtable.Mutation) error {
+	if len(rowKeys) == 0 {
+		return nil
+	}
+	errs, err := table.ApplyBulk(ctx, rowKeys, mutations)
+	if err != nil {
+		return err
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadCompanies reads every company ID, for tests that need a valid company as input.
+func (wf *BulkBigtable) loadCompanies() error {
+	return loadTableRowKeys(wf.ctx, wf.client, wf.companies, datagen.CompanyTableName)
+}
@@ -0,0 +1,119 @@
+package workflow
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigtable"
+	"github.com/r7wang/gcloud-test/datagen"
+	"github.com/r7wang/gcloud-test/timer"
+)
+
+func TestKeysCompany(t *testing.T) {
+	tests := []struct {
+		strategy string
+		want     bool
+	}{
+		{strategy: "sequential", want: false},
+		{strategy: "salted", want: false},
+		{strategy: "user-reversed-time", want: false},
+		{strategy: "field-promotion", want: true},
+	}
+	for _, tt := range tests {
+		strategy, err := datagen.ParseRowKeyStrategy(tt.strategy)
+		if err != nil {
+			t.Fatalf("ParseRowKeyStrategy(%q) returned error: %v", tt.strategy, err)
+		}
+		if got := keysCompany(strategy); got != tt.want {
+			t.Errorf("keysCompany(%s) = %v, want %v", tt.strategy, got, tt.want)
+		}
+	}
+}
+
+func TestRetagCompany(t *testing.T) {
+	const numTransactions = 3
+	for _, name := range []string{"sequential", "field-promotion"} {
+		ctx := context.Background()
+		client := newTestBigtable(t)
+		strategy, err := datagen.ParseRowKeyStrategy(name)
+		if err != nil {
+			t.Fatalf("ParseRowKeyStrategy(%q) returned error: %v", name, err)
+		}
+		config := DefaultConfig()
+		config.RowKeys = strategy
+		wf := NewBulkBigtable(ctx, client, timer.NewMetrics(), config)
+
+		companies := client.Open(datagen.CompanyTableName)
+		for _, companyID := range []string{"1", "2"} {
+			mutation := bigtable.NewMutation()
+			mutation.Set(datagen.DefaultColumnFamily, datagen.CompanyNameColumn, bigtable.Now(), []byte("Company"))
+			if err := companies.Apply(ctx, companyID, mutation); err != nil {
+				t.Fatalf("Apply() returned error: %v", err)
+			}
+		}
+		wantTimes := map[int64]time.Time{}
+		transactions := client.Open(datagen.TransactionTableName)
+		for i := int64(0); i < numTransactions; i++ {
+			key := datagen.TransactionKey{
+				ID:         datagen.TransactionBaseID + i,
+				CompanyID:  datagen.Int64String(1 + i%2),
+				FromUserID: "7",
+				Time:       time.Unix(datagen.TransactionMinTime+i, 0),
+			}
+			ts := bigtable.Time(key.Time)
+			mutation := bigtable.NewMutation()
+			mutation.Set(datagen.DefaultColumnFamily, datagen.TransactionCompanyColumn, ts, []byte(key.CompanyID))
+			mutation.Set(datagen.DefaultColumnFamily, datagen.TransactionFromUserColumn, ts, []byte(key.FromUserID))
+			mutation.Set(datagen.DefaultColumnFamily, datagen.TransactionToUserColumn, ts, []byte("8"))
+			if err := transactions.Apply(ctx, strategy.Encode(key), mutation); err != nil {
+				t.Fatalf("Apply() returned error: %v", err)
+			}
+			wantTimes[key.ID] = ts.Time()
+		}
+
+		if err := wf.loadCompanies(); err != nil {
+			t.Fatalf("%s: loadCompanies() returned error: %v", name, err)
+		}
+		if err := wf.retagCompany(ctx, rand.New(rand.NewSource(1))); err != nil {
+			t.Fatalf("%s: retagCompany() returned error: %v", name, err)
+		}
+
+		// Every transaction now belongs to the same company, under a row key that agrees with it.
+		companyIDs := map[string]bool{}
+		numRows := 0
+		err = transactions.ReadRows(ctx, bigtable.InfiniteRange(""), func(row bigtable.Row) bool {
+			numRows++
+			key, err := strategy.Decode(row.Key())
+			if err != nil {
+				t.Errorf("%s: Decode(%q) returned error: %v", name, row.Key(), err)
+				return true
+			}
+			for _, cell := range row[datagen.DefaultColumnFamily] {
+				isCompany := cell.Column == datagen.DefaultColumnFamily+":"+datagen.TransactionCompanyColumn
+				// A rewritten company cell is a new version, while a moved row keeps every time.
+				if (!isCompany || keysCompany(strategy)) && !cell.Timestamp.Time().Equal(wantTimes[key.ID]) {
+					t.Errorf("%s: %s has time %v, want %v", name, cell.Column, cell.Timestamp.Time(), wantTimes[key.ID])
+				}
+				if !isCompany {
+					continue
+				}
+				companyIDs[string(cell.Value)] = true
+				if key.CompanyID != "" && key.CompanyID != string(cell.Value) {
+					t.Errorf("%s: row key %s does not match company %s", name, row.Key(), cell.Value)
+				}
+			}
+			return true
+		}, bigtable.RowFilter(bigtable.LatestNFilter(1)))
+		if err != nil {
+			t.Fatalf("%s: ReadRows() returned error: %v", name, err)
+		}
+		if numRows != numTransactions {
+			t.Errorf("%s: retagCompany() left %d transactions, want %d", name, numRows, numTransactions)
+		}
+		if len(companyIDs) != 1 {
+			t.Errorf("%s: retagCompany() left transactions of companies %v, want one company", name, companyIDs)
+		}
+	}
+}
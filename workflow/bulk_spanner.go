@@ -0,0 +1,168 @@
+package workflow
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/r7wang/gcloud-test/datagen"
+	"github.com/r7wang/gcloud-test/timer"
+)
+
+// BulkSpanner defines operations to exercise large, set-based changes using Partitioned DML, along
+// with deletes that cascade from a user to their transactions.
+//
+// Every test is destructive, so the suite is only part of the default test sequence when
+// BulkSamples is positive.
+type BulkSpanner struct {
+	suiteBase
+	client    *spanner.Client
+	companies *keyStore
+	users     *keyStore
+	cutoff    *cutoff
+}
+
+// NewBulkSpanner returns a new BulkSpanner instance.
+func NewBulkSpanner(
+	ctx context.Context,
+	client *spanner.Client,
+	metrics *timer.Metrics,
+	config Config,
+) *BulkSpanner {
+
+	return &BulkSpanner{
+		suiteBase: newSuiteBase(ctx, metrics, config),
+		client:    client,
+		companies: &keyStore{},
+		users:     &keyStore{},
+		cutoff:    &cutoff{},
+	}
+}
+
+// Run sequentially executes all of the test workflows, with BulkSamples samples each.
+func (wf *BulkSpanner) Run() error {
+	return runTests(wf.withConfig(bulkConfig(wf.config)).tests(), wf.config)
+}
+
+// Tests returns the name of every test workflow.
+func (wf *BulkSpanner) Tests() []string {
+	return testNames(wf.tests())
+}
+
+// RunTest executes a single test workflow with the given configuration.
+func (wf *BulkSpanner) RunTest(name string, config Config) error {
+	return runNamedTest(wf.withConfig(config).tests(), name)
+}
+
+// withConfig returns a copy of the workflow that runs with the given configuration.
+func (wf *BulkSpanner) withConfig(config Config) *BulkSpanner {
+	step := *wf
+	step.suiteBase = wf.suiteBase.withConfig(config)
+	return &step
+}
+
+func (wf *BulkSpanner) tests() []test {
+	return []test{
+		prepared(wf.loadCompanies, wf.runner.test(wf.retagCompany, "Bulk.retagCompany")),
+		wf.runner.test(wf.deleteBefore, "Bulk.deleteBefore"),
+		prepared(wf.loadUsers, wf.runner.test(wf.deleteUser, deleteUserMetricName)),
+	}
+}
+
+// Move every transaction of one company over to another company using PartitionedUpdate.
+func (wf *BulkSpanner) retagCompany(ctx context.Context, r *rand.Rand) error {
+	fromIdx, toIdx, err := distinctPair(r, len(wf.companies.keys))
+	if err != nil {
+		return err
+	}
+	fromCompanyID := wf.companies.keys[fromIdx]
+	toCompanyID := wf.companies.keys[toIdx]
+	stmt := spanner.Statement{
+		SQL: `UPDATE Transactions t
+				SET t.CompanyId = @to
+				WHERE t.CompanyId = @from`,
+		Params: map[string]interface{}{
+			"from": fromCompanyID,
+			"to":   toCompanyID,
+		},
+	}
+	return wf.partitionedUpdate(ctx, stmt, "Bulk.retagCompany")
+}
+
+// Delete every transaction older than a cutoff using PartitionedUpdate. The cutoff advances with
+// every sample.
+func (wf *BulkSpanner) deleteBefore(ctx context.Context, r *rand.Rand) error {
+	stmt := spanner.Statement{
+		SQL: `DELETE FROM Transactions t
+				WHERE t.Time < @cutoff`,
+		Params: map[string]interface{}{
+			"cutoff": wf.cutoff.next(),
+		},
+	}
+	return wf.partitionedUpdate(ctx, stmt, "Bulk.deleteBefore")
+}
+
+// Delete a user along with every transaction that they sent, within a single ReadWriteTransaction.
+// When transactions are interleaved in users, deleting the user cascades to their transactions.
+// Otherwise, the transactions are deleted explicitly with DML, which is also the only case where
+// the number of deleted transactions is known. Each user is only picked once, since a deleted user
+// is gone for every later sample.
+func (wf *BulkSpanner) deleteUser(ctx context.Context, r *rand.Rand) error {
+	userID, err := wf.users.take(r)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	var rows int64
+	_, err = wf.client.ReadWriteTransaction(ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+		rows = 0
+		if !wf.config.Schema.Interleaved {
+			stmt := spanner.Statement{
+				SQL: `DELETE FROM Transactions t
+						WHERE t.FromUserId = @id`,
+				Params: map[string]interface{}{
+					"id": userID,
+				},
+			}
+			count, err := txn.Update(ctx, stmt)
+			if err != nil {
+				return err
+			}
+			rows = count
+		}
+		return txn.BufferWrite([]*spanner.Mutation{spanner.Delete(datagen.UserTableName, spanner.Key{userID})})
+	})
+	if err != nil {
+		return err
+	}
+	wf.metrics.Elapsed(start, deleteUserMetricName)
+	if !wf.config.Schema.Interleaved {
+		wf.metrics.Add(rowsMetricName(deleteUserMetricName), rows)
+	}
+	return nil
+}
+
+// partitionedUpdate runs a Partitioned DML statement, recording its wall time and the number of
+// rows that it affected. Spanner only reports a lower bound on the number of rows affected.
+func (wf *BulkSpanner) partitionedUpdate(ctx context.Context, stmt spanner.Statement, metricName string) error {
+	start := time.Now()
+	rows, err := wf.client.PartitionedUpdate(ctx, stmt)
+	if err != nil {
+		return err
+	}
+	wf.metrics.Elapsed(start, metricName)
+	wf.metrics.Add(rowsMetricName(metricName), rows)
+	return nil
+}
+
+// loadUsers reads every user ID, for tests that need a valid user as input.
+func (wf *BulkSpanner) loadUsers() error {
+	return loadTableIDs(wf.ctx, wf.client, wf.users, datagen.UserTableName, wf.config.Schema.UserIDColumn)
+}
+
+// loadCompanies reads every company ID, for tests that need a valid company as input.
+func (wf *BulkSpanner) loadCompanies() error {
+	return loadTableIDs(wf.ctx, wf.client, wf.companies, datagen.CompanyTableName, "Id")
+}
+
@@ -0,0 +1,51 @@
+package workflow
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestDistinctPair(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, n := range []int{2, 3, 10} {
+		seen := map[[2]int]bool{}
+		for i := 0; i < 1000; i++ {
+			from, to, err := distinctPair(r, n)
+			if err != nil {
+				t.Fatalf("distinctPair(%d) returned error: %v", n, err)
+			}
+			if from == to || from < 0 || from >= n || to < 0 || to >= n {
+				t.Fatalf("distinctPair(%d) = %d, %d", n, from, to)
+			}
+			seen[[2]int{from, to}] = true
+		}
+		// Every ordered pair of different indexes can be picked.
+		if len(seen) != n*(n-1) {
+			t.Errorf("distinctPair(%d) picked %d different pairs, want %d", n, len(seen), n*(n-1))
+		}
+	}
+	for _, n := range []int{0, 1} {
+		if _, _, err := distinctPair(r, n); err == nil {
+			t.Errorf("distinctPair(%d) succeeded, want an error", n)
+		}
+	}
+}
+
+func TestKeyStoreTake(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	store := &keyStore{keys: []int64{1, 2, 3, 4, 5}}
+	seen := map[int64]bool{}
+	for i := 0; i < 5; i++ {
+		key, err := store.take(r)
+		if err != nil {
+			t.Fatalf("take() #%d returned error: %v", i, err)
+		}
+		if seen[key] {
+			t.Errorf("take() returned %d twice", key)
+		}
+		seen[key] = true
+	}
+	if _, err := store.take(r); err == nil {
+		t.Error("take() from an empty store succeeded, want an error")
+	}
+}
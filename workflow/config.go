@@ -0,0 +1,205 @@
+package workflow
+
+import (
+	"flag"
+	"regexp"
+	"time"
+
+	"github.com/r7wang/gcloud-test/datagen"
+	"github.com/r7wang/gcloud-test/history"
+)
+
+// Config defines the parameters that control how each test workflow is run.
+type Config struct {
+	// Concurrency is the number of workers that share the sample budget of a test. Each worker has
+	// its own random source and at most one request in flight.
+	Concurrency int
+	// TargetQPS switches the runner to an open-loop mode when positive. Samples are scheduled on a
+	// fixed timetable at this rate instead of being sent as soon as a worker becomes free, and
+	// latency is measured from the scheduled send time. Concurrency bounds the number of requests
+	// in flight, so it must be large enough to sustain the rate.
+	TargetQPS float64
+	// NumSamples is the number of samples each test runs, unless Duration is set.
+	NumSamples int
+	// Duration runs each test for a fixed amount of wall-clock time instead of a fixed number of
+	// samples when positive. This is better suited to soak runs, since slow tests no longer take
+	// proportionally longer to finish.
+	Duration time.Duration
+	// Mix replaces the isolated transactional tests with a single mixed workload when it is not
+	// empty. See Mix for details.
+	Mix Mix
+	// Keys picks the generated transactions that the transactional tests operate on.
+	Keys datagen.KeyChooser
+	// NumReads overrides the number of rows read by each sample of the multi-row read tests when
+	// positive.
+	NumReads int
+	// Run selects only the tests whose metric names match, when set.
+	Run *regexp.Regexp
+	// Skip excludes the tests whose metric names match, when set. It takes precedence over Run.
+	Skip *regexp.Regexp
+	// Seed determines the key sequence of every test. Running the same test with the same seed
+	// and a concurrency of 1 accesses the same keys in the same order. With more workers, each
+	// worker still follows its own reproducible sequence, but samples are interleaved differently.
+	Seed int64
+	// ErrorBudget is the number of failed samples that each test tolerates. Failures are recorded
+	// by error class and the test keeps going until the budget is exceeded. A budget of 0 stops at
+	// the first error, and a negative budget never stops.
+	ErrorBudget int
+	// Timeout bounds each sample with its own deadline, derived from the workflow context, when
+	// positive. Samples that run past it are reported as Timeout errors instead of latency samples.
+	Timeout time.Duration
+	// Staleness is how far in the past the stale read variants of the Spanner tests read, when
+	// positive. Each variant reads with an exact staleness, a max staleness or a read timestamp of
+	// this age.
+	Staleness time.Duration
+	// HotRows is the number of rows that the contention test spreads its updates over, when
+	// positive. Fewer rows with more workers lead to more conflicts between transactions.
+	HotRows int64
+	// History records the operations of the register tests for an offline linearizability check,
+	// when set. The register tests are only run when it is set.
+	History *history.Recorder
+	// HistoryKeys is the number of rows that the register tests read and write. Fewer keys lead to
+	// more concurrent operations on each key, which makes anomalies more likely to be observed.
+	HistoryKeys int64
+	// BulkSamples is the number of samples that each bulk change test runs as part of the default
+	// test sequence. Bulk changes are destructive, so they are only run when it is positive, and
+	// always run closed-loop on a single worker.
+	BulkSamples int
+	// Ledger adds the backend-neutral ledger tests to the default test sequence. Most of them
+	// measure the same primitives as the OLTP tests, so they are only run when it is set, or when
+	// named in a workload.
+	Ledger bool
+	// Schema describes the schema variant of the Spanner database, which determines how users are
+	// keyed and whether deleting a user cascades to their transactions.
+	Schema datagen.SpannerSchema
+	// RowKeys is the row key strategy of the Bigtable transactions table, which decides how the
+	// tests find the row of a transaction.
+	RowKeys datagen.RowKeyStrategy
+	// StepQPS switches the runner to a step-load mode when positive. Each test starts with an
+	// open-loop offered load of StepQPS, holds it for StepDuration, then increases it by
+	// StepIncrementQPS, until the p99 latency of a step exceeds StepSLO, its error rate exceeds
+	// StepMaxErrorRate, or the offered load would exceed StepMaxQPS. Every step is recorded as a
+	// point on a throughput versus latency curve for the test.
+	StepQPS float64
+	// StepIncrementQPS is the increase in offered load between steps. It defaults to StepQPS.
+	StepIncrementQPS float64
+	// StepDuration is how long each step holds its offered load.
+	StepDuration time.Duration
+	// StepMaxQPS is the highest offered load to try, when positive.
+	StepMaxQPS float64
+	// StepSLO is the p99 latency that ends the search once exceeded, when positive.
+	StepSLO time.Duration
+	// StepMaxErrorRate is the fraction of failed samples that ends the search once exceeded.
+	StepMaxErrorRate float64
+}
+
+// DefaultConfig returns a Config that runs every sample sequentially on a single worker and picks
+// keys uniformly.
+func DefaultConfig() Config {
+	keys, _ := datagen.ParseKeyChooser("uniform", datagen.TransactionCount)
+	schema, _ := datagen.LookupSpannerSchema(datagen.DefaultSpannerSchema)
+	rowKeys, _ := datagen.ParseRowKeyStrategy(datagen.DefaultRowKeyStrategy)
+	return Config{
+		Concurrency: 1,
+		NumSamples:  NumSamples,
+		Mix:         Mix{},
+		Keys:        keys,
+		Staleness:   10 * time.Second,
+		HotRows:     10,
+		HistoryKeys: 5,
+		Schema:      schema,
+		RowKeys:     rowKeys,
+
+		StepDuration:     time.Minute,
+		StepMaxErrorRate: 0.01,
+	}
+}
+
+// numReads returns the configured number of reads, or defaultNumReads if it is not set.
+func (c Config) numReads(defaultNumReads int) int {
+	if c.NumReads > 0 {
+		return c.NumReads
+	}
+	return defaultNumReads
+}
+
+// Selected returns whether the named test passes both the Run and Skip filters.
+func (c Config) Selected(name string) bool {
+	if c.Run != nil && !c.Run.MatchString(name) {
+		return false
+	}
+	if c.Skip != nil && c.Skip.MatchString(name) {
+		return false
+	}
+	return true
+}
+
+// RegisterFlags binds each configurable parameter to a command line flag.
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.IntVar(&c.Concurrency, "concurrency", c.Concurrency, "number of workers sharing the sample budget")
+	fs.IntVar(&c.NumSamples, "samples", c.NumSamples, "number of samples per test")
+	fs.DurationVar(&c.Duration, "duration", c.Duration, "wall-clock time per test, overriding -samples (e.g. 5m)")
+	fs.Float64Var(&c.TargetQPS, "qps", c.TargetQPS, "open-loop request rate per test (0 runs closed-loop)")
+	fs.Var(&regexpValue{re: &c.Run}, "run", "only run tests whose metric names match this regular expression")
+	fs.Var(&regexpValue{re: &c.Skip}, "skip", "skip tests whose metric names match this regular expression")
+	fs.DurationVar(&c.Timeout, "timeout", c.Timeout, "deadline for each sample (e.g. 500ms; 0 disables it)")
+	fs.DurationVar(&c.Staleness, "staleness", c.Staleness, "age of the data read by the stale Spanner read tests (0 disables them)")
+	fs.Int64Var(&c.HotRows, "hot-rows", c.HotRows, "rows updated by the contention test (0 skips it)")
+	fs.Int64Var(&c.HistoryKeys, "history-keys", c.HistoryKeys, "rows read and written by the register tests when recording a history")
+	fs.IntVar(&c.BulkSamples, "bulk-samples", c.BulkSamples, "samples per destructive bulk change test (0 skips them)")
+	fs.BoolVar(&c.Ledger, "ledger", c.Ledger, "run the ledger tests, which repeat the OLTP primitives through the common backend")
+	fs.IntVar(&c.ErrorBudget, "max-errors", c.ErrorBudget, "failed samples tolerated per test (0 stops at the first error, -1 never stops)")
+	fs.Int64Var(&c.Seed, "seed", c.Seed, "seed for every random key sequence (0 picks one at random)")
+	fs.Var(&keyChooserValue{keys: &c.Keys}, "keys", "key distribution: uniform, zipfian[:theta], hotspot[:opFraction:keyFraction], latest[:theta] or sequential")
+	fs.IntVar(&c.NumReads, "num-reads", c.NumReads, "rows read per sample by multi-row read tests (0 uses each test's default)")
+	fs.Float64Var(&c.StepQPS, "step-qps", c.StepQPS, "offered load of the first step of a step-load run (0 disables step-load)")
+	fs.Float64Var(&c.StepIncrementQPS, "step-increment", c.StepIncrementQPS, "increase in offered load between steps (0 uses -step-qps)")
+	fs.DurationVar(&c.StepDuration, "step-duration", c.StepDuration, "time that each step holds its offered load")
+	fs.Float64Var(&c.StepMaxQPS, "step-max-qps", c.StepMaxQPS, "highest offered load to try (0 is unbounded)")
+	fs.DurationVar(&c.StepSLO, "step-slo", c.StepSLO, "p99 latency that ends a step-load run once exceeded (0 disables it)")
+	fs.Float64Var(&c.StepMaxErrorRate, "step-max-error-rate", c.StepMaxErrorRate, "fraction of failed samples that ends a step-load run once exceeded")
+	fs.Var(c.Mix, "mix", "weighted transactional mix, as ycsb-[a-f] or name=weight,... (e.g. simpleRandomReadRow=95,blindWrite=5)")
+}
+
+// regexpValue adapts a regular expression to the flag.Value interface.
+type regexpValue struct {
+	re **regexp.Regexp
+}
+
+func (v *regexpValue) String() string {
+	if v.re == nil || *v.re == nil {
+		return ""
+	}
+	return (*v.re).String()
+}
+
+func (v *regexpValue) Set(value string) error {
+	re, err := regexp.Compile(value)
+	if err != nil {
+		return err
+	}
+	*v.re = re
+	return nil
+}
+
+// keyChooserValue adapts a key chooser over the generated transactions to the flag.Value
+// interface.
+type keyChooserValue struct {
+	keys *datagen.KeyChooser
+}
+
+func (v *keyChooserValue) String() string {
+	if v.keys == nil || *v.keys == nil {
+		return ""
+	}
+	return (*v.keys).String()
+}
+
+func (v *keyChooserValue) Set(value string) error {
+	keys, err := datagen.ParseKeyChooser(value, datagen.TransactionCount)
+	if err != nil {
+		return err
+	}
+	*v.keys = keys
+	return nil
+}
@@ -0,0 +1,55 @@
+package workflow
+
+import (
+	"flag"
+	"io/ioutil"
+	"regexp"
+	"testing"
+)
+
+func TestConfigSelected(t *testing.T) {
+	tests := []struct {
+		run  string
+		skip string
+		name string
+		want bool
+	}{
+		{name: "OLTP.blindWrite", want: true},
+		{run: `^OLTP\.`, name: "OLTP.blindWrite", want: true},
+		{run: `^OLTP\.`, name: "OLAP.simpleTopN", want: false},
+		{skip: `Write$`, name: "OLTP.blindWrite", want: false},
+		{skip: `Write$`, name: "OLTP.delete", want: true},
+		{run: `^OLTP\.`, skip: `Write$`, name: "OLTP.blindWrite", want: false},
+	}
+	for _, tt := range tests {
+		config := DefaultConfig()
+		if tt.run != "" {
+			config.Run = regexp.MustCompile(tt.run)
+		}
+		if tt.skip != "" {
+			config.Skip = regexp.MustCompile(tt.skip)
+		}
+		if got := config.Selected(tt.name); got != tt.want {
+			t.Errorf("Selected(%s) with -run %q -skip %q = %v, want %v", tt.name, tt.run, tt.skip, got, tt.want)
+		}
+	}
+}
+
+func TestConfigSelectionFlags(t *testing.T) {
+	config := DefaultConfig()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	config.RegisterFlags(fs)
+	if err := fs.Parse([]string{"-run", `^OLTP\.`, "-skip", `Write$`}); err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if config.Run.String() != `^OLTP\.` || config.Skip.String() != `Write$` {
+		t.Errorf("Parse set -run %v -skip %v, want ^OLTP\\. and Write$", config.Run, config.Skip)
+	}
+
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	config.RegisterFlags(fs)
+	if err := fs.Parse([]string{"-run", "("}); err == nil {
+		t.Errorf("Parse of an invalid -run expression returned no error")
+	}
+}
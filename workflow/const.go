@@ -1,5 +1,5 @@
 package workflow
 
-// NumSamples is the number of times we want to run any given experiment so we can get a good
-// distribution of results.
+// NumSamples is the default number of times we want to run any given experiment so we can get a
+// good distribution of results.
 const NumSamples = 1000
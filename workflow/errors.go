@@ -0,0 +1,34 @@
+package workflow
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// timeoutClass is the error class of a sample that ran past its own deadline. It is kept separate
+// from DeadlineExceeded, which may also originate from deadlines set by the client libraries.
+const timeoutClass = "Timeout"
+
+// errorClass classifies an error by its gRPC status code, such as Aborted, DeadlineExceeded,
+// NotFound or Unavailable. Both Spanner and Bigtable surface RPC failures as gRPC status errors.
+// Errors that do not carry a status are classified as Unknown.
+func errorClass(err error) string {
+	switch err {
+	case context.DeadlineExceeded:
+		return codes.DeadlineExceeded.String()
+	case context.Canceled:
+		return codes.Canceled.String()
+	}
+	return status.Code(err).String()
+}
+
+// sampleErrorClass classifies an error returned by a sample that ran with ctx. Any error is
+// classified as a Timeout once the sample's own deadline has passed, since that is what caused it.
+func sampleErrorClass(ctx context.Context, err error) string {
+	if ctx.Err() == context.DeadlineExceeded {
+		return timeoutClass
+	}
+	return errorClass(err)
+}
@@ -0,0 +1,57 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestErrorClass(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{err: context.DeadlineExceeded, want: "DeadlineExceeded"},
+		{err: context.Canceled, want: "Canceled"},
+		{err: status.Error(codes.Aborted, "transaction aborted"), want: "Aborted"},
+		{err: status.Error(codes.Unavailable, "connection reset"), want: "Unavailable"},
+		{err: errors.New("no status"), want: "Unknown"},
+	}
+	for _, tt := range tests {
+		if got := errorClass(tt.err); got != tt.want {
+			t.Errorf("errorClass(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestSampleErrorClass(t *testing.T) {
+	expired, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-expired.Done()
+
+	// Any error is a Timeout once the sample's own deadline has passed.
+	for _, err := range []error{context.DeadlineExceeded, status.Error(codes.Unavailable, "connection reset")} {
+		if got := sampleErrorClass(expired, err); got != timeoutClass {
+			t.Errorf("sampleErrorClass(expired, %v) = %s, want %s", err, got, timeoutClass)
+		}
+	}
+
+	// A deadline set by the client libraries is not a Timeout while the sample has time left.
+	live, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	err := status.Error(codes.DeadlineExceeded, "rpc deadline")
+	if got := sampleErrorClass(live, err); got != "DeadlineExceeded" {
+		t.Errorf("sampleErrorClass(live, %v) = %s, want DeadlineExceeded", err, got)
+	}
+
+	// A sample that was canceled along with the workflow is not a Timeout either.
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if got := sampleErrorClass(canceled, context.Canceled); got != "Canceled" {
+		t.Errorf("sampleErrorClass(canceled, %v) = %s, want Canceled", context.Canceled, got)
+	}
+}
@@ -0,0 +1,47 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/api/iterator"
+)
+
+// loadTableIDs reads every ID of a table in order into store, for tests that need a valid row as
+// input. The IDs are only read once, by the first test that needs them, and are ordered so that a
+// seeded run picks the same rows every time.
+func loadTableIDs(ctx context.Context, client *spanner.Client, store *keyStore, tableName string, columnName string) error {
+	if len(store.keys) > 0 {
+		return nil
+	}
+	iter := client.Single().Query(ctx, tableIDsQuery(tableName, columnName))
+	defer iter.Stop()
+	ids := []int64{}
+	var id int64
+	for {
+		row, err := iter.Next()
+		if err != nil {
+			if err == iterator.Done {
+				break
+			}
+			return err
+		}
+		if err := row.Columns(&id); err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return fmt.Errorf("No rows found in %s", tableName)
+	}
+	store.keys = ids
+	return nil
+}
+
+// tableIDsQuery selects every ID of a table in order.
+func tableIDsQuery(tableName string, columnName string) spanner.Statement {
+	return spanner.Statement{
+		SQL: fmt.Sprintf(`SELECT %s FROM %s ORDER BY %s`, columnName, tableName, columnName),
+	}
+}
@@ -0,0 +1,28 @@
+package workflow
+
+import (
+	"testing"
+
+	"github.com/r7wang/gcloud-test/datagen"
+)
+
+func TestTableIDsQuery(t *testing.T) {
+	tests := []struct {
+		tableName  string
+		columnName string
+		want       string
+	}{
+		{tableName: datagen.UserTableName, columnName: "Id", want: "SELECT Id FROM Users ORDER BY Id"},
+		{
+			tableName:  datagen.UserTableName,
+			columnName: datagen.TransactionFromUserColumn,
+			want:       "SELECT FromUserId FROM Users ORDER BY FromUserId",
+		},
+		{tableName: datagen.CompanyTableName, columnName: "Id", want: "SELECT Id FROM Companies ORDER BY Id"},
+	}
+	for _, tt := range tests {
+		if got := tableIDsQuery(tt.tableName, tt.columnName).SQL; got != tt.want {
+			t.Errorf("tableIDsQuery(%q, %q) = %q, want %q", tt.tableName, tt.columnName, got, tt.want)
+		}
+	}
+}
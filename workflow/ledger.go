@@ -0,0 +1,242 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/r7wang/gcloud-test/datagen"
+	"github.com/r7wang/gcloud-test/timer"
+)
+
+const (
+	// transferMetricName names the transfer test, along with the counters that it reports.
+	transferMetricName = "Ledger.transfer"
+	// transferMaxAmount is the largest amount moved by a single transfer.
+	transferMaxAmount = 100
+	// balanceCheckInterval is the time between checks of the total balance while transfers run.
+	balanceCheckInterval = time.Second
+)
+
+// Ledger defines operations to exercise the common ledger workflows against any Backend. Every
+// test is named the same regardless of the backend, so that results can be compared directly
+// across databases.
+type Ledger struct {
+	suiteBase
+	backend  Backend
+	written  *keyStore
+	accounts *keyStore
+}
+
+// NewLedger returns a new Ledger instance.
+func NewLedger(
+	ctx context.Context,
+	backend Backend,
+	metrics *timer.Metrics,
+	config Config,
+) *Ledger {
+
+	return &Ledger{
+		suiteBase: newSuiteBase(ctx, metrics, config),
+		backend:   backend,
+		written:   &keyStore{},
+		accounts:  &keyStore{},
+	}
+}
+
+// Run sequentially executes all of the test workflows.
+func (wf *Ledger) Run() error {
+	return runTests(wf.tests(), wf.config)
+}
+
+// Tests returns the name of every test workflow.
+func (wf *Ledger) Tests() []string {
+	return testNames(wf.tests())
+}
+
+// RunTest executes a single test workflow with the given configuration.
+func (wf *Ledger) RunTest(name string, config Config) error {
+	return runNamedTest(wf.withConfig(config).tests(), name)
+}
+
+// withConfig returns a copy of the workflow that runs with the given configuration.
+func (wf *Ledger) withConfig(config Config) *Ledger {
+	step := *wf
+	step.suiteBase = wf.suiteBase.withConfig(config)
+	return &step
+}
+
+// tests returns every test workflow. The tests that address transactions by ID are left out if
+// the backend cannot, while the others still run. This includes blindWrite, since its rows are
+// only cleaned up by the delete test that follows it.
+func (wf *Ledger) tests() []test {
+	tests := []test{}
+	if wf.backend.Addressable() {
+		tests = append(tests,
+			wf.runner.test(wf.pointRead, "Ledger.pointRead"),
+			wf.runner.test(wf.rangeRead, "Ledger.rangeRead"),
+			wf.runner.test(wf.multiGet, "Ledger.multiGet"),
+			wf.runner.test(wf.scan, "Ledger.scan"),
+			wf.runner.test(wf.readModifyWrite, "Ledger.readModifyWrite"),
+			prepared(wf.loadAccounts, wf.runner.testReturns(wf.blindWrite, wf.written, "Ledger.blindWrite")),
+			wf.runner.testWith(wf.delete, wf.written, "Ledger.delete"))
+	}
+	return append(tests, wf.checkedTransfers())
+}
+
+func (wf *Ledger) pointRead(ctx context.Context, r *rand.Rand) error {
+	readID := datagen.ChooseTransactionID(r, wf.config.Keys)
+	_, err := wf.backend.ReadTransaction(ctx, readID)
+	return err
+}
+
+func (wf *Ledger) rangeRead(ctx context.Context, r *rand.Rand) error {
+	numReads := wf.config.numReads(100)
+
+	startReadID, endReadID := datagen.ChooseTransactionIDRange(r, wf.config.Keys, int64(numReads))
+	_, err := wf.backend.ReadTransactionRange(ctx, startReadID, endReadID)
+	return err
+}
+
+func (wf *Ledger) multiGet(ctx context.Context, r *rand.Rand) error {
+	numReads := wf.config.numReads(5)
+
+	readIDs := []int64{}
+	for i := 0; i < numReads; i++ {
+		readIDs = append(readIDs, datagen.ChooseTransactionID(r, wf.config.Keys))
+	}
+	_, err := wf.backend.ReadTransactions(ctx, readIDs)
+	return err
+}
+
+func (wf *Ledger) scan(ctx context.Context, r *rand.Rand) error {
+	numReads := wf.config.numReads(100)
+
+	startReadID := datagen.ChooseTransactionID(r, wf.config.Keys)
+	_, err := wf.backend.ScanTransactions(ctx, startReadID, int64(numReads))
+	return err
+}
+
+func (wf *Ledger) readModifyWrite(ctx context.Context, r *rand.Rand) error {
+	readID := datagen.ChooseTransactionID(r, wf.config.Keys)
+	return wf.backend.ReadModifyWriteTransaction(ctx, readID)
+}
+
+func (wf *Ledger) blindWrite(ctx context.Context, r *rand.Rand) (int64, error) {
+	// For these tests, referential integrity is un-important since there are no defined
+	// foreign key constraints. The sender is still picked from the accounts, which share their
+	// IDs with users, since a transaction interleaved in users needs an existing sender.
+	txn := Transaction{
+		ID:         r.Int63(),
+		CompanyID:  r.Int63(),
+		FromUserID: wf.accounts.keys[r.Int63n(int64(len(wf.accounts.keys)))],
+		ToUserID:   r.Int63(),
+	}
+	if err := wf.backend.WriteTransaction(ctx, txn); err != nil {
+		return 0, err
+	}
+	return txn.ID, nil
+}
+
+func (wf *Ledger) delete(ctx context.Context, r *rand.Rand, key int64) error {
+	return wf.backend.DeleteTransaction(ctx, key)
+}
+
+// checkedTransfers returns a test that runs transfers while checking that the total balance
+// across every account never changes. The total is checked before the transfers start, every
+// balanceCheckInterval while they run, and once more after they finish.
+//
+// A check that runs alongside the transfers only holds on a backend that reads the total from a
+// consistent snapshot. Any mismatch is counted as a violation. A mismatch after the transfers
+// finish means that the invariant is broken for good, so it also fails the test.
+func (wf *Ledger) checkedTransfers() test {
+	transfers := wf.runner.test(wf.transfer, transferMetricName)
+	return prepared(wf.loadAccounts, test{
+		name: transfers.name,
+		run: func() error {
+			expected, err := wf.backend.TotalBalance(wf.ctx)
+			if err != nil {
+				return err
+			}
+			stop := make(chan struct{})
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				ticker := time.NewTicker(balanceCheckInterval)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-stop:
+						return
+					case <-ticker.C:
+						wf.checkBalance(expected)
+					}
+				}
+			}()
+			err = transfers.run()
+			close(stop)
+			<-done
+
+			total, checkErr := wf.checkBalance(expected)
+			if err != nil {
+				return err
+			}
+			if checkErr != nil {
+				return checkErr
+			}
+			if total != expected {
+				return fmt.Errorf("Total balance changed from %d to %d", expected, total)
+			}
+			return nil
+		},
+	})
+}
+
+// checkBalance reads the total balance and counts a violation if it does not match expected.
+func (wf *Ledger) checkBalance(expected int64) (int64, error) {
+	start := time.Now()
+	total, err := wf.backend.TotalBalance(wf.ctx)
+	if err != nil {
+		wf.metrics.TrackError(transferMetricName+".check", errorClass(err))
+		return 0, err
+	}
+	wf.metrics.Track(start, transferMetricName+".check")
+	wf.metrics.Count(transferMetricName + " [CHECKS]")
+	if total != expected {
+		log.Printf("%s total balance is %d, expected %d", transferMetricName, total, expected)
+		wf.metrics.Count(transferMetricName + " [VIOLATIONS]")
+	}
+	return total, nil
+}
+
+func (wf *Ledger) transfer(ctx context.Context, r *rand.Rand) error {
+	numAccounts := int64(len(wf.accounts.keys))
+	fromIdx := r.Int63n(numAccounts)
+	// Picking from the remaining accounts guarantees that an account never transfers to itself.
+	toIdx := (fromIdx + 1 + r.Int63n(numAccounts-1)) % numAccounts
+	amount := 1 + r.Int63n(transferMaxAmount)
+	err := wf.backend.Transfer(ctx, wf.accounts.keys[fromIdx], wf.accounts.keys[toIdx], amount)
+	if err == errInsufficientFunds {
+		wf.metrics.Count(transferMetricName + " [DECLINED]")
+		return nil
+	}
+	return err
+}
+
+// loadAccounts reads every account ID, for tests that need valid accounts as input.
+func (wf *Ledger) loadAccounts() error {
+	if len(wf.accounts.keys) > 0 {
+		return nil
+	}
+	ids, err := wf.backend.AccountIDs(wf.ctx)
+	if err != nil {
+		return err
+	}
+	if len(ids) < 2 {
+		return fmt.Errorf("Transfers need at least 2 accounts, found %d", len(ids))
+	}
+	wf.accounts.keys = ids
+	return nil
+}
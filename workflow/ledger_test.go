@@ -0,0 +1,51 @@
+package workflow
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/r7wang/gcloud-test/timer"
+)
+
+// fakeBackend only answers whether it is addressable. Every other operation panics.
+type fakeBackend struct {
+	Backend
+	addressable bool
+}
+
+func (b fakeBackend) Addressable() bool {
+	return b.addressable
+}
+
+func TestLedgerTests(t *testing.T) {
+	tests := []struct {
+		addressable bool
+		want        []string
+	}{
+		{
+			addressable: true,
+			want: []string{
+				"Ledger.pointRead",
+				"Ledger.rangeRead",
+				"Ledger.multiGet",
+				"Ledger.scan",
+				"Ledger.readModifyWrite",
+				"Ledger.blindWrite",
+				"Ledger.delete",
+				transferMetricName,
+			},
+		},
+		{
+			addressable: false,
+			want:        []string{transferMetricName},
+		},
+	}
+	for _, tt := range tests {
+		backend := fakeBackend{addressable: tt.addressable}
+		ledger := NewLedger(context.Background(), backend, timer.NewMetrics(), DefaultConfig())
+		if got := ledger.Tests(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Tests() with addressable=%v = %v, want %v", tt.addressable, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// mixMetricName is the name of the mixed workload test.
+const mixMetricName = "OLTP.mix"
+
+// Roles used by the YCSB workloads. Each backend maps these onto one of its own operations.
+const (
+	ycsbRead            = "read"
+	ycsbUpdate          = "update"
+	ycsbInsert          = "insert"
+	ycsbScan            = "scan"
+	ycsbReadModifyWrite = "readModifyWrite"
+)
+
+// ycsbWorkloads defines the standard YCSB core workloads in terms of generic roles. Workload D
+// reads the latest records, so it should be combined with the latest key distribution.
+//
+// See the link below for more information:
+//		https://github.com/brianfrankcooper/YCSB/wiki/Core-Workloads
+var ycsbWorkloads = map[string]Mix{
+	"ycsb-a": {ycsbRead: 50, ycsbUpdate: 50},
+	"ycsb-b": {ycsbRead: 95, ycsbUpdate: 5},
+	"ycsb-c": {ycsbRead: 100},
+	"ycsb-d": {ycsbRead: 95, ycsbInsert: 5},
+	"ycsb-e": {ycsbScan: 95, ycsbInsert: 5},
+	"ycsb-f": {ycsbRead: 50, ycsbReadModifyWrite: 50},
+}
+
+// errMixSkip indicates that a mix operation was picked but had nothing to work on, in which case
+// another operation is picked for the same sample.
+var errMixSkip = errors.New("mix operation skipped")
+
+// Mix assigns a relative weight to each operation that takes part in a mixed workload. Operations
+// are named either by their workflow name (e.g. simpleRandomReadRow) or by a YCSB role (read,
+// update, insert, scan, readModifyWrite).
+type Mix map[string]int
+
+// String returns the mix in the same format accepted by Set.
+func (m Mix) String() string {
+	names := []string{}
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := []string{}
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s=%d", name, m[name]))
+	}
+	return strings.Join(parts, ",")
+}
+
+// Set parses either a predefined YCSB workload (ycsb-a through ycsb-f) or a comma separated list
+// of name=weight pairs, such as "simpleRandomReadRow=95,blindWrite=5".
+func (m Mix) Set(value string) error {
+	for name := range m {
+		delete(m, name)
+	}
+	if workload, ok := ycsbWorkloads[strings.ToLower(value)]; ok {
+		for name, weight := range workload {
+			m[name] = weight
+		}
+		return nil
+	}
+	for _, part := range strings.Split(value, ",") {
+		pair := strings.SplitN(part, "=", 2)
+		if len(pair) != 2 {
+			return fmt.Errorf("Invalid mix entry %s", part)
+		}
+		weight, err := strconv.Atoi(pair[1])
+		if err != nil || weight < 0 {
+			return fmt.Errorf("Invalid weight for mix entry %s", part)
+		}
+		m[strings.TrimSpace(pair[0])] = weight
+	}
+	return nil
+}
+
+// mixTestNames returns the names of the tests that a suite with a mixed workload runs: only the
+// mixed workload when a mix is configured, and every isolated test otherwise.
+func mixTestNames(config Config, tests []test) []string {
+	if len(config.Mix) > 0 {
+		return []string{mixMetricName}
+	}
+	return testNames(tests)
+}
+
+// mixOperation is a single operation that can be picked as part of a mixed workload.
+type mixOperation struct {
+	name     string
+	testFunc func(ctx context.Context, r *rand.Rand) error
+	// ready reports whether the operation currently has anything to work on. It may be nil.
+	ready func() bool
+	// cleanup undoes whatever the operation left behind, once the mixed workload ends. It may be
+	// nil.
+	cleanup func(ctx context.Context, r *rand.Rand) error
+}
+
+// weightedOperation is a mixOperation with its configured weight.
+type weightedOperation struct {
+	mixOperation
+	weight int
+}
+
+// resolveMix matches each entry in the mix to one of the available operations. YCSB roles are
+// translated to operation names through roles.
+func resolveMix(mix Mix, ops []mixOperation, roles map[string]string) ([]weightedOperation, error) {
+	opsByName := map[string]mixOperation{}
+	for _, op := range ops {
+		opsByName[op.name] = op
+	}
+	weightsByName := map[string]int{}
+	for name, weight := range mix {
+		if role, ok := roles[name]; ok {
+			name = role
+		}
+		if _, ok := opsByName[name]; !ok {
+			return nil, fmt.Errorf("Unsupported mix operation %s", name)
+		}
+		weightsByName[name] += weight
+	}
+
+	// Keep the order stable so that a seeded run picks the same operations.
+	weighted := []weightedOperation{}
+	for _, op := range ops {
+		if weight := weightsByName[op.name]; weight > 0 {
+			weighted = append(weighted, weightedOperation{mixOperation: op, weight: weight})
+		}
+	}
+	if len(weighted) == 0 {
+		return nil, errors.New("Mix does not contain any operation with a positive weight")
+	}
+	return weighted, nil
+}
+
+// pickOperation picks an operation at random, proportional to its weight, out of the operations
+// that are ready.
+func pickOperation(r *rand.Rand, ops []weightedOperation) (*weightedOperation, error) {
+	total := 0
+	for i := range ops {
+		if ops[i].ready == nil || ops[i].ready() {
+			total += ops[i].weight
+		}
+	}
+	if total == 0 {
+		return nil, errors.New("No operation in the mix is ready to run")
+	}
+	pick := r.Intn(total)
+	for i := range ops {
+		if ops[i].ready != nil && !ops[i].ready() {
+			continue
+		}
+		if pick < ops[i].weight {
+			return &ops[i], nil
+		}
+		pick -= ops[i].weight
+	}
+	return nil, errors.New("No operation in the mix is ready to run")
+}
+
+// keyPool holds keys written during a mixed workload so that they can be deleted by later
+// operations in the same workload.
+type keyPool struct {
+	mu   sync.Mutex
+	keys []int64
+}
+
+func (p *keyPool) push(key int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keys = append(p.keys, key)
+}
+
+func (p *keyPool) pop() (int64, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.keys) == 0 {
+		return 0, false
+	}
+	key := p.keys[len(p.keys)-1]
+	p.keys = p.keys[:len(p.keys)-1]
+	return key, true
+}
+
+// drain removes every key from the pool and returns them.
+func (p *keyPool) drain() []int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	keys := p.keys
+	p.keys = nil
+	return keys
+}
+
+func (p *keyPool) ready() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.keys) > 0
+}
+
+// mixKeyOperations wraps a write that returns a key and a delete that consumes one, so that a
+// mixed workload only deletes rows that it wrote itself. Any row that is still left once the
+// workload ends is deleted then, so that repeated runs do not grow the dataset.
+func mixKeyOperations(
+	writeName string,
+	writeFunc func(ctx context.Context, r *rand.Rand) (int64, error),
+	deleteName string,
+	deleteFunc func(ctx context.Context, r *rand.Rand, key int64) error,
+) []mixOperation {
+
+	pool := &keyPool{}
+	return []mixOperation{
+		{
+			name: writeName,
+			testFunc: func(ctx context.Context, r *rand.Rand) error {
+				key, err := writeFunc(ctx, r)
+				if err != nil {
+					return err
+				}
+				pool.push(key)
+				return nil
+			},
+		},
+		{
+			name: deleteName,
+			testFunc: func(ctx context.Context, r *rand.Rand) error {
+				key, ok := pool.pop()
+				if !ok {
+					return errMixSkip
+				}
+				return deleteFunc(ctx, r, key)
+			},
+			ready: pool.ready,
+			cleanup: func(ctx context.Context, r *rand.Rand) error {
+				keys := pool.drain()
+				for i, key := range keys {
+					if err := deleteFunc(ctx, r, key); err != nil {
+						return fmt.Errorf("%d rows written by %s were left behind: %v", len(keys)-i, writeName, err)
+					}
+				}
+				return nil
+			},
+		},
+	}
+}
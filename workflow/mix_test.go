@@ -0,0 +1,203 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMixSet(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Mix
+		wantErr bool
+	}{
+		{value: "ycsb-a", want: Mix{ycsbRead: 50, ycsbUpdate: 50}},
+		{value: "YCSB-E", want: Mix{ycsbScan: 95, ycsbInsert: 5}},
+		{value: "simpleRandomReadRow=95, blindWrite=5", want: Mix{"simpleRandomReadRow": 95, "blindWrite": 5}},
+		{value: "simpleRandomReadRow", wantErr: true},
+		{value: "simpleRandomReadRow=x", wantErr: true},
+		{value: "simpleRandomReadRow=-1", wantErr: true},
+	}
+	for _, tt := range tests {
+		// Set replaces any previous entries.
+		m := Mix{"stale": 1}
+		err := m.Set(tt.value)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Set(%q) = %v, want an error", tt.value, m)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Set(%q) returned error: %v", tt.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(m, tt.want) {
+			t.Errorf("Set(%q) = %v, want %v", tt.value, m, tt.want)
+		}
+	}
+}
+
+func TestMixString(t *testing.T) {
+	m := Mix{"b": 2, "a": 1}
+	if got, want := m.String(), "a=1,b=2"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	parsed := Mix{}
+	if err := parsed.Set(m.String()); err != nil {
+		t.Fatalf("Set(%q) returned error: %v", m.String(), err)
+	}
+	if !reflect.DeepEqual(parsed, m) {
+		t.Errorf("Set(String()) = %v, want %v", parsed, m)
+	}
+}
+
+func TestResolveMix(t *testing.T) {
+	ops := []mixOperation{{name: "readRow"}, {name: "writeRow"}, {name: "scanRows"}}
+	roles := map[string]string{ycsbRead: "readRow", ycsbUpdate: "writeRow"}
+	tests := []struct {
+		mix     Mix
+		want    map[string]int
+		wantErr bool
+	}{
+		{mix: Mix{ycsbRead: 95, ycsbUpdate: 5}, want: map[string]int{"readRow": 95, "writeRow": 5}},
+		{mix: Mix{ycsbRead: 50, "readRow": 10, "scanRows": 0}, want: map[string]int{"readRow": 60}},
+		{mix: Mix{ycsbInsert: 5}, wantErr: true},
+		{mix: Mix{"unknown": 5}, wantErr: true},
+		{mix: Mix{"readRow": 0}, wantErr: true},
+	}
+	for _, tt := range tests {
+		weighted, err := resolveMix(tt.mix, ops, roles)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("resolveMix(%v) = %v, want an error", tt.mix, weighted)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("resolveMix(%v) returned error: %v", tt.mix, err)
+			continue
+		}
+		got := map[string]int{}
+		for _, op := range weighted {
+			got[op.name] = op.weight
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("resolveMix(%v) = %v, want %v", tt.mix, got, tt.want)
+		}
+	}
+}
+
+func TestPickOperation(t *testing.T) {
+	ready := true
+	ops := []weightedOperation{
+		{mixOperation: mixOperation{name: "heavy"}, weight: 3},
+		{mixOperation: mixOperation{name: "light"}, weight: 1},
+		{mixOperation: mixOperation{name: "gated", ready: func() bool { return ready }}, weight: 4},
+	}
+	r := rand.New(rand.NewSource(1))
+	const picks = 80000
+	counts := map[string]int{}
+	for i := 0; i < picks; i++ {
+		op, err := pickOperation(r, ops)
+		if err != nil {
+			t.Fatalf("pickOperation returned error: %v", err)
+		}
+		counts[op.name]++
+	}
+	// Each operation is picked in proportion to its weight, out of a total weight of 8.
+	for _, op := range ops {
+		want := picks * op.weight / 8
+		if got := counts[op.name]; got < want*9/10 || got > want*11/10 {
+			t.Errorf("pickOperation picked %s %d times, want about %d", op.name, got, want)
+		}
+	}
+
+	ready = false
+	for i := 0; i < 1000; i++ {
+		op, err := pickOperation(r, ops)
+		if err != nil {
+			t.Fatalf("pickOperation returned error: %v", err)
+		}
+		if op.name == "gated" {
+			t.Fatalf("pickOperation picked %s, which is not ready", op.name)
+		}
+	}
+
+	if op, err := pickOperation(r, ops[2:]); err == nil {
+		t.Errorf("pickOperation = %s with no operation ready, want an error", op.name)
+	}
+}
+
+// fakeKeyTable counts the rows written and deleted by mixKeyOperations. The delete of failKey
+// fails.
+type fakeKeyTable struct {
+	mu      sync.Mutex
+	nextKey int64
+	rows    map[int64]bool
+	failKey int64
+}
+
+func (f *fakeKeyTable) write(ctx context.Context, r *rand.Rand) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextKey++
+	f.rows[f.nextKey] = true
+	return f.nextKey, nil
+}
+
+func (f *fakeKeyTable) delete(ctx context.Context, r *rand.Rand, key int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if key == f.failKey {
+		return errors.New("delete failed")
+	}
+	delete(f.rows, key)
+	return nil
+}
+
+func TestRunMixDrainsKeys(t *testing.T) {
+	const numSamples = 20
+	table := &fakeKeyTable{rows: map[int64]bool{}}
+	r := testRunner(4)
+	r.config.NumSamples = numSamples
+	r.config.Mix = Mix{"blindWrite": 3, "delete": 1}
+
+	ops := mixKeyOperations("blindWrite", table.write, "delete", table.delete)
+	if err := r.runMix(ops, nil, "Test.mix"); err != nil {
+		t.Fatalf("runMix returned error: %v", err)
+	}
+	if table.nextKey == 0 {
+		t.Fatal("runMix wrote no rows")
+	}
+	if len(table.rows) != 0 {
+		t.Errorf("runMix left %d of the %d rows it wrote", len(table.rows), table.nextKey)
+	}
+}
+
+func TestMixKeyOperationsCleanupFailure(t *testing.T) {
+	table := &fakeKeyTable{rows: map[int64]bool{}, failKey: 2}
+	ops := mixKeyOperations("blindWrite", table.write, "delete", table.delete)
+	ctx := context.Background()
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 3; i++ {
+		if err := ops[0].testFunc(ctx, r); err != nil {
+			t.Fatalf("%s returned error: %v", ops[0].name, err)
+		}
+	}
+
+	// The rows are deleted in the order they were written, so the second one and every row after
+	// it are left behind.
+	err := ops[1].cleanup(ctx, r)
+	if err == nil || !strings.HasPrefix(err.Error(), "2 rows written by blindWrite were left behind") {
+		t.Errorf("cleanup() error = %v, want 2 rows left behind", err)
+	}
+	if ops[1].ready() {
+		t.Errorf("cleanup() left keys in the pool")
+	}
+}
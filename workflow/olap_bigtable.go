@@ -1,9 +1,13 @@
 package workflow
 
 import (
+	"container/heap"
 	"context"
 	"fmt"
 	"math/rand"
+	"regexp"
+	"sort"
+	"time"
 
 	"cloud.google.com/go/bigtable"
 	"github.com/r7wang/gcloud-test/datagen"
@@ -13,10 +17,9 @@ import (
 // OLAPBigtable defines operations to exercise common types of analytical workflows across large
 // chunks of data.
 type OLAPBigtable struct {
-	ctx     context.Context
-	runner  *runner
-	client  *bigtable.Client
-	metrics *timer.Metrics
+	suiteBase
+	client *bigtable.Client
+	users  *rowKeyStore
 }
 
 // NewOLAPBigtable returns a new OLAPBigtable instance.
@@ -24,58 +27,157 @@ func NewOLAPBigtable(
 	ctx context.Context,
 	client *bigtable.Client,
 	metrics *timer.Metrics,
+	config Config,
 ) *OLAPBigtable {
 
 	return &OLAPBigtable{
-		ctx:     ctx,
-		runner:  newRunner(metrics),
-		client:  client,
-		metrics: metrics,
+		suiteBase: newSuiteBase(ctx, metrics, config),
+		client:    client,
+		users:     &rowKeyStore{},
 	}
 }
 
 // Run sequentially executes all of the test workflows.
 func (wf *OLAPBigtable) Run() error {
-	if err := wf.runner.runTest(wf.simpleTopN, "OLAP.simpleTopN"); err != nil {
-		return err
+	return runTests(wf.tests(), wf.config)
+}
+
+// Tests returns the name of every test workflow.
+func (wf *OLAPBigtable) Tests() []string {
+	return testNames(wf.tests())
+}
+
+// RunTest executes a single test workflow with the given configuration.
+func (wf *OLAPBigtable) RunTest(name string, config Config) error {
+	return runNamedTest(wf.withConfig(config).tests(), name)
+}
+
+// withConfig returns a copy of the workflow that runs with the given configuration.
+func (wf *OLAPBigtable) withConfig(config Config) *OLAPBigtable {
+	step := *wf
+	step.suiteBase = wf.suiteBase.withConfig(config)
+	return &step
+}
+
+func (wf *OLAPBigtable) tests() []test {
+	return []test{
+		wf.runner.test(wf.simpleTopN, "OLAP.simpleTopN"),
+		wf.runner.test(wf.aggregationTopN, "OLAP.aggregationTopN"),
+		prepared(wf.loadUsers, wf.runner.test(wf.targetedOrderedScan, "OLAP.targetedOrderedScan")),
 	}
-	if err := wf.runner.runTest(wf.aggregationTopN, "OLAP.aggregationTopN"); err != nil {
+}
+
+// Scan every transaction for the time it took place, keeping the most recent ones.
+//
+// Bigtable has no secondary indexes, so ordering by anything other than the row key requires a
+// full table scan. Transaction times are stored as cell timestamps, so the scan only needs to
+// return a single cell per row, without its value.
+func (wf *OLAPBigtable) simpleTopN(ctx context.Context, r *rand.Rand) error {
+	const topN = 100
+
+	times := &timeHeap{}
+	table := wf.client.Open(datagen.TransactionTableName)
+	err := table.ReadRows(ctx, bigtable.InfiniteRange(""), func(row bigtable.Row) bool {
+		for _, cell := range row[datagen.DefaultColumnFamily] {
+			heap.Push(times, cell.Timestamp.Time())
+			if times.Len() > topN {
+				heap.Pop(times)
+			}
+		}
+		return true
+	}, bigtable.RowFilter(wf.timeFilter()))
+	if err != nil {
 		return err
 	}
-	if err := wf.runner.runTest(wf.targetedOrderedScan, "OLAP.targetedOrderedScan"); err != nil {
+	return nil
+}
+
+// Scan every transaction and count them by month on the client, ordering the months by count.
+func (wf *OLAPBigtable) aggregationTopN(ctx context.Context, r *rand.Rand) error {
+	countsByMonth := map[time.Month]int64{}
+	table := wf.client.Open(datagen.TransactionTableName)
+	err := table.ReadRows(ctx, bigtable.InfiniteRange(""), func(row bigtable.Row) bool {
+		for _, cell := range row[datagen.DefaultColumnFamily] {
+			countsByMonth[cell.Timestamp.Time().UTC().Month()]++
+		}
+		return true
+	}, bigtable.RowFilter(wf.timeFilter()))
+	if err != nil {
 		return err
 	}
+
+	months := []time.Month{}
+	for month := range countsByMonth {
+		months = append(months, month)
+	}
+	sort.Slice(months, func(i, j int) bool {
+		return countsByMonth[months[i]] > countsByMonth[months[j]]
+	})
 	return nil
 }
 
-func (wf *OLAPBigtable) simpleTopN(r *rand.Rand) error {
-	/*
-		table := wf.client.Open(datagen.TransactionTableName)
-		rowRange := bigtable.RowList(readIDs)
-		if err := table.ReadRows(wf.ctx, rowRange, wf.scanRow); err != nil {
-			return err
+// Scan every transaction sent by a single user and order them by time.
+//
+// When the row key strategy groups transactions by sender, only the rows under the prefix of the
+// user are read. Otherwise, every row is scanned and filtered on the server. The users are loaded
+// before the test starts, so that each sample only times the scan itself.
+func (wf *OLAPBigtable) targetedOrderedScan(ctx context.Context, r *rand.Rand) error {
+	readID := wf.users.keys[r.Intn(len(wf.users.keys))]
+
+	var rowSet bigtable.RowSet = bigtable.InfiniteRange("")
+	filter := wf.timeFilter()
+	if prefix, ok := wf.config.RowKeys.UserPrefix(readID); ok {
+		rowSet = bigtable.PrefixRange(prefix)
+	} else {
+		// The server only returns the FromUserId cell of matching rows, and rows without any
+		// cells left after filtering are omitted entirely.
+		filter = bigtable.ChainFilters(
+			bigtable.ColumnFilter(fmt.Sprintf("^%s$", datagen.TransactionFromUserColumn)),
+			bigtable.ValueFilter(fmt.Sprintf("^%s$", regexp.QuoteMeta(readID))),
+			bigtable.LatestNFilter(1),
+			bigtable.StripValueFilter())
+	}
+	times := []time.Time{}
+	table := wf.client.Open(datagen.TransactionTableName)
+	err := table.ReadRows(ctx, rowSet, func(row bigtable.Row) bool {
+		for _, cell := range row[datagen.DefaultColumnFamily] {
+			times = append(times, cell.Timestamp.Time())
 		}
-	*/
+		return true
+	}, bigtable.RowFilter(filter))
+	if err != nil {
+		return err
+	}
+	sort.Slice(times, func(i, j int) bool {
+		return times[i].After(times[j])
+	})
 	return nil
 }
 
-func (wf *OLAPBigtable) aggregationTopN(r *rand.Rand) error {
-	return nil
+// timeFilter reduces each transaction to a single cell without its value, which is enough to
+// read the time of the transaction from the cell timestamp.
+func (wf *OLAPBigtable) timeFilter() bigtable.Filter {
+	return bigtable.ChainFilters(
+		bigtable.ColumnFilter(fmt.Sprintf("^%s$", datagen.TransactionFromUserColumn)),
+		bigtable.LatestNFilter(1),
+		bigtable.StripValueFilter())
 }
 
-func (wf *OLAPBigtable) targetedOrderedScan(r *rand.Rand) error {
-	return nil
+// loadUsers reads every user ID, for tests that need a valid user as input.
+func (wf *OLAPBigtable) loadUsers() error {
+	return loadTableRowKeys(wf.ctx, wf.client, wf.users, datagen.UserTableName)
 }
 
-func (wf *OLAPBigtable) scanRow(row bigtable.Row) bool {
-	cf := row[datagen.DefaultColumnFamily]
-	for _, col := range cf {
-		if col.Column == fmt.Sprintf("%s:%s", datagen.DefaultColumnFamily, datagen.TransactionFromUserColumn) {
-			continue
-		}
-		if col.Column == fmt.Sprintf("%s:%s", datagen.DefaultColumnFamily, datagen.TransactionToUserColumn) {
-			continue
-		}
-	}
-	return true
+// timeHeap is a min-heap of times, used to keep the most recent times seen during a scan.
+type timeHeap []time.Time
+
+func (h timeHeap) Len() int            { return len(h) }
+func (h timeHeap) Less(i, j int) bool  { return h[i].Before(h[j]) }
+func (h timeHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *timeHeap) Push(x interface{}) { *h = append(*h, x.(time.Time)) }
+func (h *timeHeap) Pop() interface{} {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
 }
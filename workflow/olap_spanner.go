@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"sort"
 	"time"
 
 	"cloud.google.com/go/spanner"
@@ -15,10 +16,10 @@ import (
 // OLAPSpanner defines operations to exercise common types of analytical workflows across large
 // chunks of data.
 type OLAPSpanner struct {
-	ctx     context.Context
-	runner  *runner
-	client  *spanner.Client
-	metrics *timer.Metrics
+	suiteBase
+	client *spanner.Client
+	bound  *readBound
+	users  *keyStore
 }
 
 // NewOLAPSpanner returns a new OLAPSpanner instance.
@@ -26,13 +27,13 @@ func NewOLAPSpanner(
 	ctx context.Context,
 	client *spanner.Client,
 	metrics *timer.Metrics,
+	config Config,
 ) *OLAPSpanner {
 
 	return &OLAPSpanner{
-		ctx:     ctx,
-		runner:  newRunner(metrics),
-		client:  client,
-		metrics: metrics,
+		suiteBase: newSuiteBase(ctx, metrics, config),
+		client:    client,
+		users:     &keyStore{},
 	}
 }
 
@@ -46,26 +47,63 @@ func NewOLAPSpanner(
 //       entities. For example, different types of users transacting to each other, where the
 //       transaction is a certain type of transaction.
 func (wf *OLAPSpanner) Run() error {
-	if err := wf.runner.runTest(wf.simpleTopN, "OLAP.simpleTopN"); err != nil {
-		return err
-	}
-	if err := wf.runner.runTest(wf.aggregationTopN, "OLAP.aggregationTopN"); err != nil {
-		return err
+	return runTests(wf.tests(), wf.config)
+}
+
+// Tests returns the name of every test workflow.
+func (wf *OLAPSpanner) Tests() []string {
+	return testNames(wf.tests())
+}
+
+// RunTest executes a single test workflow with the given configuration.
+func (wf *OLAPSpanner) RunTest(name string, config Config) error {
+	return runNamedTest(wf.withConfig(config).tests(), name)
+}
+
+// withBound returns a copy of the workflow whose single-use reads use the given timestamp bound.
+func (wf *OLAPSpanner) withBound(bound readBound) *OLAPSpanner {
+	step := *wf
+	step.bound = &bound
+	return &step
+}
+
+// single returns a single-use read-only transaction with the timestamp bound of the workflow.
+func (wf *OLAPSpanner) single() *spanner.ReadOnlyTransaction {
+	return singleUse(wf.client, wf.bound)
+}
+
+// withConfig returns a copy of the workflow that runs with the given configuration.
+func (wf *OLAPSpanner) withConfig(config Config) *OLAPSpanner {
+	step := *wf
+	step.suiteBase = wf.suiteBase.withConfig(config)
+	return &step
+}
+
+func (wf *OLAPSpanner) tests() []test {
+	tests := []test{
+		wf.runner.test(wf.simpleTopN, "OLAP.simpleTopN"),
+		wf.runner.test(wf.aggregationTopN, "OLAP.aggregationTopN"),
+		prepared(wf.loadUsers, wf.runner.test(wf.targetedOrderedScan, "OLAP.targetedOrderedScan")),
+		wf.runner.test(wf.partitionedAggregationTopN, "OLAP.partitionedAggregationTopN"),
 	}
-	if err := wf.runner.runTest(wf.targetedOrderedScan, "OLAP.targetedOrderedScan"); err != nil {
-		return err
+	for _, bound := range staleReadBounds(wf.config.Staleness) {
+		stale := wf.withBound(bound)
+		tests = append(tests,
+			wf.runner.test(stale.simpleTopN, "OLAP.simpleTopN."+bound.name),
+			wf.runner.test(stale.aggregationTopN, "OLAP.aggregationTopN."+bound.name),
+			prepared(wf.loadUsers, wf.runner.test(stale.targetedOrderedScan, "OLAP.targetedOrderedScan."+bound.name)))
 	}
-	return nil
+	return tests
 }
 
-func (wf *OLAPSpanner) simpleTopN(r *rand.Rand) error {
+func (wf *OLAPSpanner) simpleTopN(ctx context.Context, r *rand.Rand) error {
 	stmt := spanner.Statement{
 		SQL: `SELECT t.Time
 				FROM Transactions t
 				ORDER BY t.Time DESC
 				LIMIT 100`,
 	}
-	iter := wf.client.Single().Query(wf.ctx, stmt)
+	iter := wf.single().Query(ctx, stmt)
 	defer iter.Stop()
 	if err := wf.scanIteratorTime(iter); err != nil {
 		return err
@@ -73,7 +111,7 @@ func (wf *OLAPSpanner) simpleTopN(r *rand.Rand) error {
 	return nil
 }
 
-func (wf *OLAPSpanner) aggregationTopN(r *rand.Rand) error {
+func (wf *OLAPSpanner) aggregationTopN(ctx context.Context, r *rand.Rand) error {
 	stmt := spanner.Statement{
 		SQL: `SELECT agg.Month, agg.TransactionCount
 				FROM
@@ -84,7 +122,7 @@ func (wf *OLAPSpanner) aggregationTopN(r *rand.Rand) error {
 				) agg
 				ORDER BY agg.TransactionCount DESC`,
 	}
-	iter := wf.client.Single().Query(wf.ctx, stmt)
+	iter := wf.single().Query(ctx, stmt)
 	defer iter.Stop()
 	if err := wf.scanIteratorMonthCount(iter); err != nil {
 		return err
@@ -92,60 +130,173 @@ func (wf *OLAPSpanner) aggregationTopN(r *rand.Rand) error {
 	return nil
 }
 
-// TODO: The way this test is written is not optimal. It actually requires an ID as input, hence
-//       there are two queries within one test.
-func (wf *OLAPSpanner) targetedOrderedScan(r *rand.Rand) error {
-	userIDs, err := wf.queryIds(datagen.UserTableName)
+// maxPartitionReaders bounds the number of partitions of a partitioned query that are read at the
+// same time within a single sample.
+const maxPartitionReaders = 16
+
+// Count every transaction by month, the same as aggregationTopN, but split the scan into
+// partitions with a BatchReadOnlyTransaction. The partitions are read in parallel, by at most
+// maxPartitionReaders readers, and merged on the client, ordering the months by count. This is how
+// a batch job would read the entire table.
+func (wf *OLAPSpanner) partitionedAggregationTopN(ctx context.Context, r *rand.Rand) error {
+	const metricName = "OLAP.partitionedAggregationTopN"
+
+	bound := spanner.StrongRead()
+	if wf.bound != nil {
+		bound = wf.bound.bound()
+	}
+	txn, err := wf.client.BatchReadOnlyTransaction(ctx, bound)
 	if err != nil {
 		return err
 	}
-	readIdx := r.Int31() % int32(len(userIDs))
-	readID := userIDs[readIdx]
+	defer txn.Cleanup(ctx)
 
 	start := time.Now()
 	stmt := spanner.Statement{
 		SQL: `SELECT t.Time
-				FROM Transactions t
-				WHERE t.FromUserId = @id
-				ORDER BY t.Time DESC`,
-		Params: map[string]interface{}{
-			"id": readID,
-		},
+				FROM Transactions t`,
+	}
+	partitions, err := txn.PartitionQuery(ctx, stmt, spanner.PartitionOptions{})
+	if err != nil {
+		return err
+	}
+	wf.metrics.Track(start, fmt.Sprintf("%s.PartitionQuery", metricName))
+	wf.metrics.Observe(fmt.Sprintf("%s [PARTITIONS]", metricName), int64(len(partitions)))
+
+	countsByMonth, err := countPartitionsByMonth(len(partitions), maxPartitionReaders, func(i int) (map[time.Month]int64, error) {
+		start := time.Now()
+		countsByMonth, err := wf.countByMonth(txn.Execute(ctx, partitions[i]))
+		if err == nil {
+			wf.metrics.Track(start, fmt.Sprintf("%s.Partition", metricName))
+		}
+		return countsByMonth, err
+	})
+	if err != nil {
+		return err
+	}
+
+	months := []time.Month{}
+	for month := range countsByMonth {
+		months = append(months, month)
+	}
+	sort.Slice(months, func(i, j int) bool {
+		return countsByMonth[months[i]] > countsByMonth[months[j]]
+	})
+	return nil
+}
+
+// countPartitionsByMonth counts transactions by month across numPartitions partitions, reading
+// each with read. At most maxReaders partitions are read at the same time. Every partition is read
+// even if some fail, and the error of the first failed partition, in partition order, is returned.
+func countPartitionsByMonth(
+	numPartitions int,
+	maxReaders int,
+	read func(partition int) (map[time.Month]int64, error),
+) (map[time.Month]int64, error) {
+
+	type partitionResult struct {
+		partition     int
+		countsByMonth map[time.Month]int64
+		err           error
+	}
+	pending := make(chan int, numPartitions)
+	for i := 0; i < numPartitions; i++ {
+		pending <- i
+	}
+	close(pending)
+	readers := maxReaders
+	if numPartitions < readers {
+		readers = numPartitions
 	}
-	iter := wf.client.Single().Query(wf.ctx, stmt)
+	results := make(chan partitionResult, numPartitions)
+	for i := 0; i < readers; i++ {
+		go func() {
+			for partition := range pending {
+				countsByMonth, err := read(partition)
+				results <- partitionResult{partition: partition, countsByMonth: countsByMonth, err: err}
+			}
+		}()
+	}
+
+	countsByMonth := map[time.Month]int64{}
+	errs := make([]error, numPartitions)
+	for i := 0; i < numPartitions; i++ {
+		result := <-results
+		if result.err != nil {
+			errs[result.partition] = result.err
+			continue
+		}
+		for month, count := range result.countsByMonth {
+			countsByMonth[month] += count
+		}
+	}
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return countsByMonth, nil
+}
+
+// Read every transaction sent by a single user, ordered by time. The users are loaded before the
+// test starts, so that each sample only times the scan itself.
+func (wf *OLAPSpanner) targetedOrderedScan(ctx context.Context, r *rand.Rand) error {
+	readIdx := r.Int31() % int32(len(wf.users.keys))
+	readID := wf.users.keys[readIdx]
+
+	start := time.Now()
+	iter := wf.single().Query(ctx, targetedOrderedScanQuery(readID))
 	defer iter.Stop()
 	if err := wf.scanIteratorTime(iter); err != nil {
 		return err
 	}
-	wf.metrics.Track(start, "OLAP.targetedOrderedScan.SQL")
+	metricName := "OLAP.targetedOrderedScan.SQL"
+	if wf.bound != nil {
+		metricName += "." + wf.bound.name
+	}
+	wf.metrics.Track(start, metricName)
 	return nil
 }
 
-func (wf *OLAPSpanner) queryIds(tableName string) ([]int64, error) {
-	stmt := spanner.Statement{
-		SQL: fmt.Sprintf(`SELECT Id FROM %s`, tableName),
+// targetedOrderedScanQuery selects the time of every transaction sent by a user, most recent first.
+func targetedOrderedScanQuery(userID int64) spanner.Statement {
+	return spanner.Statement{
+		SQL: `SELECT t.Time
+				FROM Transactions t
+				WHERE t.FromUserId = @id
+				ORDER BY t.Time DESC`,
+		Params: map[string]interface{}{
+			"id": userID,
+		},
 	}
-	iter := wf.client.Single().Query(wf.ctx, stmt)
-	defer iter.Stop()
-	ids := []int64{}
-	var id int64
+}
+
+// loadUsers reads every user ID, for tests that need a valid user as input.
+func (wf *OLAPSpanner) loadUsers() error {
+	return loadTableIDs(wf.ctx, wf.client, wf.users, datagen.UserTableName, wf.config.Schema.UserIDColumn)
+}
+
+func (wf *OLAPSpanner) scanIteratorTime(iter *spanner.RowIterator) error {
+	var time time.Time
 	for {
 		row, err := iter.Next()
 		if err != nil {
 			if err == iterator.Done {
 				break
 			}
-			return nil, err
+			return err
 		}
-		if err := row.Columns(&id); err != nil {
-			return nil, err
+		if err := row.Columns(&time); err != nil {
+			return err
 		}
-		ids = append(ids, id)
 	}
-	return ids, nil
+	return nil
 }
 
-func (wf *OLAPSpanner) scanIteratorTime(iter *spanner.RowIterator) error {
+// countByMonth counts the rows of an iterator over transaction times by month.
+func (wf *OLAPSpanner) countByMonth(iter *spanner.RowIterator) (map[time.Month]int64, error) {
+	defer iter.Stop()
+	countsByMonth := map[time.Month]int64{}
 	var time time.Time
 	for {
 		row, err := iter.Next()
@@ -153,13 +304,14 @@ func (wf *OLAPSpanner) scanIteratorTime(iter *spanner.RowIterator) error {
 			if err == iterator.Done {
 				break
 			}
-			return err
+			return nil, err
 		}
 		if err := row.Columns(&time); err != nil {
-			return err
+			return nil, err
 		}
+		countsByMonth[time.UTC().Month()]++
 	}
-	return nil
+	return countsByMonth, nil
 }
 
 func (wf *OLAPSpanner) scanIteratorMonthCount(iter *spanner.RowIterator) error {
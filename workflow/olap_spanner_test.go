@@ -0,0 +1,138 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/r7wang/gcloud-test/timer"
+)
+
+func TestTargetedOrderedScanQuery(t *testing.T) {
+	stmt := targetedOrderedScanQuery(7)
+	for _, clause := range []string{"FROM Transactions t", "WHERE t.FromUserId = @id", "ORDER BY t.Time DESC"} {
+		if !strings.Contains(stmt.SQL, clause) {
+			t.Errorf("targetedOrderedScanQuery() = %q, want it to contain %q", stmt.SQL, clause)
+		}
+	}
+	wantParams := map[string]interface{}{"id": int64(7)}
+	if !reflect.DeepEqual(stmt.Params, wantParams) {
+		t.Errorf("targetedOrderedScanQuery() params = %v, want %v", stmt.Params, wantParams)
+	}
+}
+
+func TestOLAPSpannerStaleUsers(t *testing.T) {
+	wf := NewOLAPSpanner(context.Background(), nil, timer.NewMetrics(), DefaultConfig())
+	// The stale read variants of the targeted scan must reuse the users loaded by the strong one,
+	// rather than query them again.
+	for _, bound := range staleReadBounds(wf.config.Staleness) {
+		if stale := wf.withBound(bound); stale.users != wf.users {
+			t.Errorf("%s: withBound() does not share the loaded users", bound.name)
+		}
+	}
+}
+
+func TestCountPartitionsByMonth(t *testing.T) {
+	partitions := []map[time.Month]int64{
+		{time.January: 1, time.February: 2},
+		{time.February: 3},
+		{},
+		{time.January: 4, time.December: 5},
+	}
+	var mu sync.Mutex
+	read := map[int]int{}
+	got, err := countPartitionsByMonth(len(partitions), 2, func(partition int) (map[time.Month]int64, error) {
+		mu.Lock()
+		read[partition]++
+		mu.Unlock()
+		return partitions[partition], nil
+	})
+	if err != nil {
+		t.Fatalf("countPartitionsByMonth() returned error: %v", err)
+	}
+	want := map[time.Month]int64{time.January: 5, time.February: 5, time.December: 5}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("countPartitionsByMonth() = %v, want %v", got, want)
+	}
+	wantRead := map[int]int{0: 1, 1: 1, 2: 1, 3: 1}
+	if !reflect.DeepEqual(read, wantRead) {
+		t.Errorf("countPartitionsByMonth() read partitions %v, want %v", read, wantRead)
+	}
+}
+
+func TestCountPartitionsByMonthNoPartitions(t *testing.T) {
+	got, err := countPartitionsByMonth(0, maxPartitionReaders, func(partition int) (map[time.Month]int64, error) {
+		t.Errorf("countPartitionsByMonth() read partition %d, want none", partition)
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("countPartitionsByMonth() returned error: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("countPartitionsByMonth() = %v, want empty", got)
+	}
+}
+
+func TestCountPartitionsByMonthFirstError(t *testing.T) {
+	const numPartitions = 8
+	// The later partition fails first, so the error returned must not depend on arrival order.
+	release := make(chan struct{})
+	var mu sync.Mutex
+	numRead := 0
+	_, err := countPartitionsByMonth(numPartitions, numPartitions, func(partition int) (map[time.Month]int64, error) {
+		defer func() {
+			mu.Lock()
+			numRead++
+			mu.Unlock()
+		}()
+		switch partition {
+		case 3:
+			<-release
+			return nil, fmt.Errorf("partition %d", partition)
+		case 6:
+			defer close(release)
+			return nil, fmt.Errorf("partition %d", partition)
+		}
+		return map[time.Month]int64{time.March: 1}, nil
+	})
+	if err == nil || err.Error() != "partition 3" {
+		t.Errorf("countPartitionsByMonth() error = %v, want partition 3", err)
+	}
+	if numRead != numPartitions {
+		t.Errorf("countPartitionsByMonth() read %d partitions, want %d", numRead, numPartitions)
+	}
+}
+
+func TestCountPartitionsByMonthReaders(t *testing.T) {
+	const numPartitions = 20
+	const maxReaders = 3
+	var mu sync.Mutex
+	active, maxActive := 0, 0
+	_, err := countPartitionsByMonth(numPartitions, maxReaders, func(partition int) (map[time.Month]int64, error) {
+		mu.Lock()
+		active++
+		if active > maxActive {
+			maxActive = active
+		}
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		active--
+		mu.Unlock()
+		if partition == numPartitions-1 {
+			return nil, errors.New("last partition")
+		}
+		return nil, nil
+	})
+	if err == nil {
+		t.Errorf("countPartitionsByMonth() returned no error, want last partition")
+	}
+	if maxActive > maxReaders {
+		t.Errorf("countPartitionsByMonth() read %d partitions at once, want at most %d", maxActive, maxReaders)
+	}
+}
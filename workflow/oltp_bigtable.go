@@ -4,19 +4,22 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"regexp"
 
 	"cloud.google.com/go/bigtable"
 	"github.com/r7wang/gcloud-test/datagen"
 	"github.com/r7wang/gcloud-test/timer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // OLTPBigtable defines operations to exercise common types of transactional workflows with certain
 // semantic guarantees.
 type OLTPBigtable struct {
-	ctx     context.Context
-	runner  *runner
+	suiteBase
 	client  *bigtable.Client
-	metrics *timer.Metrics
+	written *keyStore
+	rowKeys *transactionRowKeys
 }
 
 // NewOLTPBigtable returns a new OLTPBigtable instance.
@@ -24,48 +27,112 @@ func NewOLTPBigtable(
 	ctx context.Context,
 	client *bigtable.Client,
 	metrics *timer.Metrics,
+	config Config,
 ) *OLTPBigtable {
 
 	return &OLTPBigtable{
-		ctx:     ctx,
-		runner:  newRunner(metrics),
-		client:  client,
-		metrics: metrics,
+		suiteBase: newSuiteBase(ctx, metrics, config),
+		client:    client,
+		written:   &keyStore{},
+		rowKeys:   &transactionRowKeys{strategy: config.RowKeys},
 	}
 }
 
-// Run sequentially executes all of the test workflows.
+// Run sequentially executes all of the test workflows, or the mixed workload instead when a mix is
+// configured.
 //
-// Bigtable does not support atomically swapping data within two columns of a single row. Consider
-// adding tests for atomicIncrement, conditionalWrite.
+// Bigtable does not support atomically swapping data within two columns of a single row. Instead,
+// atomicIncrement and conditionalWrite measure the single-row primitives that could replace a
+// transaction for simple counters.
+//
+// Transactions are picked by ID, and their row keys are found through the row key strategy. When
+// the strategy cannot build a row key from an ID, a sample of row keys is loaded before the tests
+// that read or update existing transactions.
 func (wf *OLTPBigtable) Run() error {
-	if err := wf.runner.runTest(wf.simpleRandomReadRow, "OLTP.simpleRandomReadRow"); err != nil {
-		return err
+	if len(wf.config.Mix) > 0 {
+		return wf.RunMix()
 	}
-	if err := wf.runner.runTest(wf.multiSequentialRead, "OLTP.multiSequentialRead"); err != nil {
-		return err
+	return runTests(wf.tests(), wf.config)
+}
+
+// Tests returns the name of every test workflow that Run executes, which is only the mixed
+// workload when a mix is configured.
+func (wf *OLTPBigtable) Tests() []string {
+	return mixTestNames(wf.config, wf.tests())
+}
+
+func (wf *OLTPBigtable) isolatedTests() []string {
+	return testNames(wf.tests())
+}
+
+// RunTest executes a single test workflow with the given configuration.
+func (wf *OLTPBigtable) RunTest(name string, config Config) error {
+	step := wf.withConfig(config)
+	if name == mixMetricName {
+		return step.RunMix()
 	}
-	if err := wf.runner.runTest(wf.multiRandomRead, "OLTP.multiRandomRead"); err != nil {
-		return err
+	return runNamedTest(step.tests(), name)
+}
+
+// withConfig returns a copy of the workflow that runs with the given configuration.
+func (wf *OLTPBigtable) withConfig(config Config) *OLTPBigtable {
+	step := *wf
+	step.suiteBase = wf.suiteBase.withConfig(config)
+	return &step
+}
+
+func (wf *OLTPBigtable) tests() []test {
+	tests := []test{
+		prepared(wf.loadRowKeys, wf.runner.test(wf.simpleRandomReadRow, "OLTP.simpleRandomReadRow")),
+		prepared(wf.loadRowKeys, wf.runner.test(wf.multiSequentialRead, "OLTP.multiSequentialRead")),
+		prepared(wf.loadRowKeys, wf.runner.test(wf.multiRandomRead, "OLTP.multiRandomRead")),
+		prepared(wf.loadRowKeys, wf.runner.test(wf.atomicAppend, "OLTP.atomicAppend")),
+		prepared(wf.loadRowKeys, wf.runner.test(wf.atomicIncrement, "OLTP.atomicIncrement")),
+		prepared(wf.loadRowKeys, wf.runner.test(wf.conditionalWrite, "OLTP.conditionalWrite")),
+		wf.runner.testReturns(wf.blindWrite, wf.written, "OLTP.blindWrite"),
+		wf.runner.testWith(wf.delete, wf.written, "OLTP.delete"),
+	}
+	if wf.config.History != nil {
+		tests = append(tests, wf.runner.registerTest(register{read: wf.readRegister, write: wf.writeRegister}))
 	}
+	return tests
+}
 
-	if err := wf.runner.runTest(wf.atomicAppend, "OLTP.atomicAppend"); err != nil {
-		return err
+// RunMix executes a single mixed workload that interleaves the test workflows according to the
+// configured mix weights.
+//
+// YCSB updates and read-modify-writes both map to atomicAppend, which is the only way to update an
+// existing row without blindly overwriting it.
+func (wf *OLTPBigtable) RunMix() error {
+	if !wf.config.Selected(mixMetricName) {
+		return nil
 	}
-	keys, err := wf.runner.runTestReturns(wf.blindWrite, "OLTP.blindWrite")
-	if err != nil {
+	if err := wf.loadRowKeys(); err != nil {
 		return err
 	}
-	if err := wf.runner.runTestWith(wf.delete, keys, "OLTP.delete"); err != nil {
-		return err
+	ops := []mixOperation{
+		{name: "simpleRandomReadRow", testFunc: wf.simpleRandomReadRow},
+		{name: "multiSequentialRead", testFunc: wf.multiSequentialRead},
+		{name: "multiRandomRead", testFunc: wf.multiRandomRead},
+		{name: "atomicAppend", testFunc: wf.atomicAppend},
+		{name: "atomicIncrement", testFunc: wf.atomicIncrement},
+		{name: "conditionalWrite", testFunc: wf.conditionalWrite},
 	}
-	return nil
+	ops = append(ops, mixKeyOperations("blindWrite", wf.blindWrite, "delete", wf.delete)...)
+	roles := map[string]string{
+		ycsbRead:            "simpleRandomReadRow",
+		ycsbUpdate:          "atomicAppend",
+		ycsbInsert:          "blindWrite",
+		ycsbScan:            "multiSequentialRead",
+		ycsbReadModifyWrite: "atomicAppend",
+	}
+	return wf.runner.runMix(ops, roles, mixMetricName)
 }
 
-func (wf *OLTPBigtable) simpleRandomReadRow(r *rand.Rand) error {
-	readID := datagen.RandomGeneratedTransactionIDString(r)
+func (wf *OLTPBigtable) simpleRandomReadRow(ctx context.Context, r *rand.Rand) error {
+	readID := wf.rowKeys.key(datagen.ChooseTransactionID(r, wf.config.Keys))
 	table := wf.client.Open(datagen.TransactionTableName)
-	row, err := table.ReadRow(wf.ctx, readID)
+	row, err := table.ReadRow(ctx, readID)
 	if err != nil {
 		return err
 	}
@@ -73,40 +140,55 @@ func (wf *OLTPBigtable) simpleRandomReadRow(r *rand.Rand) error {
 	return nil
 }
 
-func (wf *OLTPBigtable) multiSequentialRead(r *rand.Rand) error {
-	const numReads = 100
+func (wf *OLTPBigtable) multiSequentialRead(ctx context.Context, r *rand.Rand) error {
+	numReads := wf.config.numReads(100)
 
-	startReadID, endReadID := datagen.RandomGeneratedTransactionIDStringRange(r, numReads)
+	// Reading a number of rows from a starting key, rather than a range between two keys, reads
+	// the same rows as an ID range with sequential keys, and also works with every other strategy.
+	startReadID, _ := datagen.ChooseTransactionIDRange(r, wf.config.Keys, int64(numReads))
 	table := wf.client.Open(datagen.TransactionTableName)
-	rowRange := bigtable.NewRange(startReadID, endReadID)
-	if err := table.ReadRows(wf.ctx, rowRange, wf.scanRow); err != nil {
+	rowRange := bigtable.InfiniteRange(wf.rowKeys.key(startReadID))
+	if err := table.ReadRows(ctx, rowRange, wf.scanRow, bigtable.LimitRows(int64(numReads))); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (wf *OLTPBigtable) multiRandomRead(r *rand.Rand) error {
-	const numReads = 5
+func (wf *OLTPBigtable) multiRandomRead(ctx context.Context, r *rand.Rand) error {
+	numReads := wf.config.numReads(5)
 
 	readIDs := []string{}
 	for i := 0; i < numReads; i++ {
-		readID := datagen.RandomGeneratedTransactionIDString(r)
+		readID := wf.rowKeys.key(datagen.ChooseTransactionID(r, wf.config.Keys))
 		readIDs = append(readIDs, readID)
 	}
 	table := wf.client.Open(datagen.TransactionTableName)
 	rowRange := bigtable.RowList(readIDs)
-	if err := table.ReadRows(wf.ctx, rowRange, wf.scanRow); err != nil {
+	if err := table.ReadRows(ctx, rowRange, wf.scanRow); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (wf *OLTPBigtable) atomicAppend(r *rand.Rand) error {
-	readID := datagen.RandomGeneratedTransactionIDString(r)
+func (wf *OLTPBigtable) atomicAppend(ctx context.Context, r *rand.Rand) error {
+	readID := wf.rowKeys.key(datagen.ChooseTransactionID(r, wf.config.Keys))
 	rw := bigtable.NewReadModifyWrite()
 	rw.AppendValue(datagen.DefaultColumnFamily, datagen.TransactionToUserColumn, []byte("-test"))
 	table := wf.client.Open(datagen.TransactionTableName)
-	row, err := table.ApplyReadModifyWrite(wf.ctx, readID, rw)
+	row, err := table.ApplyReadModifyWrite(ctx, readID, rw)
+	if err != nil {
+		return err
+	}
+	wf.scanRow(row)
+	return nil
+}
+
+func (wf *OLTPBigtable) atomicIncrement(ctx context.Context, r *rand.Rand) error {
+	readID := wf.rowKeys.key(datagen.ChooseTransactionID(r, wf.config.Keys))
+	rw := bigtable.NewReadModifyWrite()
+	rw.Increment(datagen.DefaultColumnFamily, datagen.TransactionRevisionColumn, 1)
+	table := wf.client.Open(datagen.TransactionTableName)
+	row, err := table.ApplyReadModifyWrite(ctx, readID, rw)
 	if err != nil {
 		return err
 	}
@@ -114,32 +196,126 @@ func (wf *OLTPBigtable) atomicAppend(r *rand.Rand) error {
 	return nil
 }
 
-func (wf *OLTPBigtable) blindWrite(r *rand.Rand) (int64, error) {
+// conditionalWrite performs a compare-and-set on the receiver of a transaction. It reads the
+// current receiver, then replaces it only if the row still holds the value that was read. A
+// concurrent change to the receiver fails the sample with Aborted, which mirrors how a Spanner
+// transaction reports a conflict.
+func (wf *OLTPBigtable) conditionalWrite(ctx context.Context, r *rand.Rand) error {
+	readID := wf.rowKeys.key(datagen.ChooseTransactionID(r, wf.config.Keys))
+	table := wf.client.Open(datagen.TransactionTableName)
+	row, err := table.ReadRow(ctx, readID, bigtable.RowFilter(receiverFilter()))
+	if err != nil {
+		return err
+	}
+	cells := row[datagen.DefaultColumnFamily]
+	if len(cells) == 0 {
+		return status.Errorf(codes.NotFound, "transaction %s not found", readID)
+	}
+	return wf.swapReceiver(ctx, readID, string(cells[0].Value), datagen.Int64String(r.Int63()))
+}
+
+// swapReceiver replaces the receiver of a transaction, but only if the latest receiver is still
+// oldReceiver. It fails with Aborted otherwise.
+func (wf *OLTPBigtable) swapReceiver(ctx context.Context, rowKey string, oldReceiver string, newReceiver string) error {
+	filter := bigtable.ChainFilters(
+		receiverFilter(),
+		bigtable.ValueFilter(fmt.Sprintf("^%s$", regexp.QuoteMeta(oldReceiver))))
+	mutation := bigtable.NewMutation()
+	mutation.Set(
+		datagen.DefaultColumnFamily,
+		datagen.TransactionToUserColumn,
+		bigtable.Now(),
+		[]byte(newReceiver))
+	var matched bool
+	table := wf.client.Open(datagen.TransactionTableName)
+	err := table.Apply(
+		ctx,
+		rowKey,
+		bigtable.NewCondMutation(filter, mutation, nil),
+		bigtable.GetCondMutationResult(&matched))
+	if err != nil {
+		return err
+	}
+	if !matched {
+		return status.Errorf(codes.Aborted, "transaction %s changed before it was written", rowKey)
+	}
+	return nil
+}
+
+// receiverFilter reduces a transaction to the latest version of its receiver.
+func receiverFilter() bigtable.Filter {
+	return bigtable.ChainFilters(
+		bigtable.ColumnFilter(fmt.Sprintf("^%s$", datagen.TransactionToUserColumn)),
+		bigtable.LatestNFilter(1))
+}
+
+// readRegister reads the latest value of a register.
+func (wf *OLTPBigtable) readRegister(ctx context.Context, id int64) (string, error) {
+	table := wf.client.Open(datagen.RegisterTableName)
+	row, err := table.ReadRow(
+		ctx,
+		datagen.Int64String(id),
+		bigtable.RowFilter(bigtable.ChainFilters(
+			bigtable.ColumnFilter(fmt.Sprintf("^%s$", datagen.RegisterValueColumn)),
+			bigtable.LatestNFilter(1))))
+	if err != nil {
+		return "", err
+	}
+	cells := row[datagen.DefaultColumnFamily]
+	if len(cells) == 0 {
+		return "", status.Errorf(codes.NotFound, "register %d not found", id)
+	}
+	return string(cells[0].Value), nil
+}
+
+// writeRegister blindly replaces the value of a register.
+func (wf *OLTPBigtable) writeRegister(ctx context.Context, id int64, value string) error {
+	mutation := bigtable.NewMutation()
+	mutation.Set(datagen.DefaultColumnFamily, datagen.RegisterValueColumn, bigtable.Now(), []byte(value))
+	table := wf.client.Open(datagen.RegisterTableName)
+	return table.Apply(ctx, datagen.Int64String(id), mutation)
+}
+
+// blindWrite writes a transaction whose row key fields are derived from its ID, so that delete can
+// find the row again from the ID alone.
+func (wf *OLTPBigtable) blindWrite(ctx context.Context, r *rand.Rand) (int64, error) {
 	// For these tests, referential integrity is un-important since there are no defined
 	// foreign key constraints.
 	addID := r.Int63()
-	ts := bigtable.Now()
+	key := writtenTransaction(addID)
+	ts := bigtable.Time(key.Time)
 	mutation := bigtable.NewMutation()
-	mutation.Set(datagen.DefaultColumnFamily, datagen.TransactionCompanyColumn, ts, []byte(datagen.Int64String(r.Int63())))
-	mutation.Set(datagen.DefaultColumnFamily, datagen.TransactionFromUserColumn, ts, []byte(datagen.Int64String(r.Int63())))
+	mutation.Set(datagen.DefaultColumnFamily, datagen.TransactionCompanyColumn, ts, []byte(key.CompanyID))
+	mutation.Set(datagen.DefaultColumnFamily, datagen.TransactionFromUserColumn, ts, []byte(key.FromUserID))
 	mutation.Set(datagen.DefaultColumnFamily, datagen.TransactionToUserColumn, ts, []byte(datagen.Int64String(r.Int63())))
 	table := wf.client.Open(datagen.TransactionTableName)
-	if err := table.Apply(wf.ctx, datagen.Int64String(addID), mutation); err != nil {
+	if err := table.Apply(ctx, wf.config.RowKeys.Encode(key), mutation); err != nil {
 		return 0, err
 	}
 	return addID, nil
 }
 
-func (wf *OLTPBigtable) delete(r *rand.Rand, key int64) error {
+func (wf *OLTPBigtable) delete(ctx context.Context, r *rand.Rand, key int64) error {
 	mutation := bigtable.NewMutation()
 	mutation.DeleteRow()
 	table := wf.client.Open(datagen.TransactionTableName)
-	if err := table.Apply(wf.ctx, datagen.Int64String(key), mutation); err != nil {
+	if err := table.Apply(ctx, wf.config.RowKeys.Encode(writtenTransaction(key)), mutation); err != nil {
 		return err
 	}
 	return nil
 }
 
+// loadRowKeys samples row keys if the strategy needs them. The sample is in row key order rather
+// than generation order, so key distributions that follow generation order are rejected.
+func (wf *OLTPBigtable) loadRowKeys() error {
+	if !wf.config.RowKeys.Addressable() && wf.config.Keys != nil && datagen.Ordered(wf.config.Keys) {
+		return fmt.Errorf(
+			"Key distribution %s does not apply to row keys [%s], which are picked from a sample",
+			wf.config.Keys, wf.config.RowKeys.Name())
+	}
+	return wf.rowKeys.load(wf.ctx, wf.client)
+}
+
 func (wf *OLTPBigtable) scanRow(row bigtable.Row) bool {
 	cf := row[datagen.DefaultColumnFamily]
 	for _, col := range cf {
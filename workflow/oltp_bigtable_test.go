@@ -0,0 +1,137 @@
+package workflow
+
+import (
+	"context"
+	"encoding/binary"
+	"math/rand"
+	"testing"
+
+	"cloud.google.com/go/bigtable"
+	"github.com/r7wang/gcloud-test/datagen"
+	"github.com/r7wang/gcloud-test/timer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newTestOLTPBigtable returns an OLTPBigtable over an in-memory Bigtable server. Its samples pick
+// transactions in ID order, starting at the first generated ID, with sequential row keys.
+func newTestOLTPBigtable(t *testing.T) (*OLTPBigtable, *bigtable.Client) {
+	t.Helper()
+	client := newTestBigtable(t)
+	config := DefaultConfig()
+	keys, err := datagen.ParseKeyChooser("sequential", datagen.TransactionCount)
+	if err != nil {
+		t.Fatalf("ParseKeyChooser() returned error: %v", err)
+	}
+	config.Keys = keys
+	rowKeys, err := datagen.ParseRowKeyStrategy("sequential")
+	if err != nil {
+		t.Fatalf("ParseRowKeyStrategy() returned error: %v", err)
+	}
+	config.RowKeys = rowKeys
+	return NewOLTPBigtable(context.Background(), client, timer.NewMetrics(), config), client
+}
+
+// writeReceiver writes the receiver of a transaction as a new version of its cell.
+func writeReceiver(t *testing.T, client *bigtable.Client, rowKey string, receiver string) {
+	t.Helper()
+	mutation := bigtable.NewMutation()
+	mutation.Set(datagen.DefaultColumnFamily, datagen.TransactionToUserColumn, bigtable.Now(), []byte(receiver))
+	table := client.Open(datagen.TransactionTableName)
+	if err := table.Apply(context.Background(), rowKey, mutation); err != nil {
+		t.Fatalf("Apply() returned error: %v", err)
+	}
+}
+
+// readColumn returns the latest value of a column of a transaction.
+func readColumn(t *testing.T, client *bigtable.Client, rowKey string, column string) []byte {
+	t.Helper()
+	table := client.Open(datagen.TransactionTableName)
+	row, err := table.ReadRow(context.Background(), rowKey, bigtable.RowFilter(bigtable.ChainFilters(
+		bigtable.ColumnFilter("^"+column+"$"),
+		bigtable.LatestNFilter(1))))
+	if err != nil {
+		t.Fatalf("ReadRow() returned error: %v", err)
+	}
+	cells := row[datagen.DefaultColumnFamily]
+	if len(cells) == 0 {
+		t.Fatalf("ReadRow() found no %s in %s", column, rowKey)
+	}
+	return cells[0].Value
+}
+
+func TestAtomicIncrement(t *testing.T) {
+	wf, client := newTestOLTPBigtable(t)
+	rowKey := wf.rowKeys.key(datagen.TransactionBaseID)
+	writeReceiver(t, client, rowKey, "2")
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 3; i++ {
+		// The sequential chooser moves on to the next transaction after every pick.
+		wf.config.Keys.Reset()
+		if err := wf.atomicIncrement(context.Background(), r); err != nil {
+			t.Fatalf("atomicIncrement() returned error: %v", err)
+		}
+	}
+	value := readColumn(t, client, rowKey, datagen.TransactionRevisionColumn)
+	if got := int64(binary.BigEndian.Uint64(value)); got != 3 {
+		t.Errorf("atomicIncrement() revision = %d, want 3", got)
+	}
+	if got := string(readColumn(t, client, rowKey, datagen.TransactionToUserColumn)); got != "2" {
+		t.Errorf("atomicIncrement() receiver = %s, want 2", got)
+	}
+}
+
+func TestConditionalWrite(t *testing.T) {
+	wf, client := newTestOLTPBigtable(t)
+	rowKey := wf.rowKeys.key(datagen.TransactionBaseID)
+	writeReceiver(t, client, rowKey, "2")
+
+	if err := wf.conditionalWrite(context.Background(), rand.New(rand.NewSource(1))); err != nil {
+		t.Fatalf("conditionalWrite() returned error: %v", err)
+	}
+	want := datagen.Int64String(rand.New(rand.NewSource(1)).Int63())
+	if got := string(readColumn(t, client, rowKey, datagen.TransactionToUserColumn)); got != want {
+		t.Errorf("conditionalWrite() receiver = %s, want %s", got, want)
+	}
+}
+
+func TestConditionalWriteNotFound(t *testing.T) {
+	wf, _ := newTestOLTPBigtable(t)
+	err := wf.conditionalWrite(context.Background(), rand.New(rand.NewSource(1)))
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("conditionalWrite() error = %v, want code %v", err, codes.NotFound)
+	}
+}
+
+func TestSwapReceiver(t *testing.T) {
+	tests := []struct {
+		name string
+		// receivers are written in order, so the last one is the latest version.
+		receivers   []string
+		oldReceiver string
+		wantCode    codes.Code
+		want        string
+	}{
+		{name: "matched", receivers: []string{"12"}, oldReceiver: "12", wantCode: codes.OK, want: "3"},
+		{name: "changed", receivers: []string{"12"}, oldReceiver: "11", wantCode: codes.Aborted, want: "12"},
+		{name: "prefix", receivers: []string{"12"}, oldReceiver: "1", wantCode: codes.Aborted, want: "12"},
+		{name: "pattern", receivers: []string{"12"}, oldReceiver: "1.", wantCode: codes.Aborted, want: "12"},
+		{name: "older version", receivers: []string{"11", "12"}, oldReceiver: "11", wantCode: codes.Aborted, want: "12"},
+	}
+	for _, tt := range tests {
+		wf, client := newTestOLTPBigtable(t)
+		rowKey := wf.rowKeys.key(datagen.TransactionBaseID)
+		for _, receiver := range tt.receivers {
+			writeReceiver(t, client, rowKey, receiver)
+		}
+
+		err := wf.swapReceiver(context.Background(), rowKey, tt.oldReceiver, "3")
+		if status.Code(err) != tt.wantCode {
+			t.Errorf("%s: swapReceiver() error = %v, want code %v", tt.name, err, tt.wantCode)
+		}
+		if got := string(readColumn(t, client, rowKey, datagen.TransactionToUserColumn)); got != tt.want {
+			t.Errorf("%s: swapReceiver() receiver = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
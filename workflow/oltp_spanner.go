@@ -2,7 +2,9 @@ package workflow
 
 import (
 	"context"
+	"fmt"
 	"math/rand"
+	"time"
 
 	"cloud.google.com/go/spanner"
 	"github.com/r7wang/gcloud-test/datagen"
@@ -13,60 +15,154 @@ import (
 // OLTPSpanner defines operations to exercise common types of transactional workflows with certain
 // semantic guarantees.
 type OLTPSpanner struct {
-	ctx    context.Context
-	runner *runner
-	client *spanner.Client
+	suiteBase
+	client  *spanner.Client
+	bound   *readBound
+	written *keyStore
+	users   *keyStore
 }
 
+// contendedSwapMetricName names the contention test, along with the counters that it reports.
+const contendedSwapMetricName = "OLTP.contendedSwap"
+
 // NewOLTPSpanner returns a new OLTPSpanner instance.
 func NewOLTPSpanner(
 	ctx context.Context,
 	client *spanner.Client,
 	metrics *timer.Metrics,
+	config Config,
 ) *OLTPSpanner {
 
+	metrics.Ratio(
+		contendedSwapMetricName+" [ABORT RATE]",
+		contendedSwapMetricName+" [RETRIES]",
+		contendedSwapMetricName+" [ATTEMPTS]")
+	metrics.Ratio(
+		contendedSwapMetricName+" [RETRY RATE]",
+		contendedSwapMetricName+" [RETRIED]",
+		contendedSwapMetricName+" [TRANSACTIONS]")
 	return &OLTPSpanner{
-		ctx:    ctx,
-		runner: newRunner(metrics),
-		client: client}
+		suiteBase: newSuiteBase(ctx, metrics, config),
+		client:    client,
+		written:   &keyStore{},
+		users:     &keyStore{},
+	}
 }
 
-// Run sequentially executes all of the test workflows.
+// Run sequentially executes all of the test workflows, or the mixed workload instead when a mix is
+// configured.
 //
 // Consider adding a multiple random read test that uses a SQL query.
 func (wf *OLTPSpanner) Run() error {
-	if err := wf.runner.runTest(wf.simpleRandomReadRow, "OLTP.simpleRandomReadRow"); err != nil {
-		return err
+	if len(wf.config.Mix) > 0 {
+		return wf.RunMix()
 	}
-	if err := wf.runner.runTest(wf.simpleRandomQuery, "OLTP.simpleRandomQuery"); err != nil {
-		return err
+	return runTests(wf.tests(), wf.config)
+}
+
+// Tests returns the name of every test workflow that Run executes, which is only the mixed
+// workload when a mix is configured.
+func (wf *OLTPSpanner) Tests() []string {
+	return mixTestNames(wf.config, wf.tests())
+}
+
+func (wf *OLTPSpanner) isolatedTests() []string {
+	return testNames(wf.tests())
+}
+
+// RunTest executes a single test workflow with the given configuration.
+func (wf *OLTPSpanner) RunTest(name string, config Config) error {
+	step := wf.withConfig(config)
+	if name == mixMetricName {
+		return step.RunMix()
 	}
-	if err := wf.runner.runTest(wf.multiSequentialRead, "OLTP.multiSequentialRead"); err != nil {
-		return err
+	return runNamedTest(step.tests(), name)
+}
+
+// withBound returns a copy of the workflow whose single-use reads use the given timestamp bound.
+func (wf *OLTPSpanner) withBound(bound readBound) *OLTPSpanner {
+	step := *wf
+	step.bound = &bound
+	return &step
+}
+
+// single returns a single-use read-only transaction with the timestamp bound of the workflow.
+func (wf *OLTPSpanner) single() *spanner.ReadOnlyTransaction {
+	return singleUse(wf.client, wf.bound)
+}
+
+// withConfig returns a copy of the workflow that runs with the given configuration.
+func (wf *OLTPSpanner) withConfig(config Config) *OLTPSpanner {
+	step := *wf
+	step.suiteBase = wf.suiteBase.withConfig(config)
+	return &step
+}
+
+func (wf *OLTPSpanner) tests() []test {
+	tests := []test{
+		wf.runner.test(wf.simpleRandomReadRow, "OLTP.simpleRandomReadRow"),
+		wf.runner.test(wf.simpleRandomQuery, "OLTP.simpleRandomQuery"),
+		wf.runner.test(wf.multiSequentialRead, "OLTP.multiSequentialRead"),
+		wf.runner.test(wf.multiRandomRead, "OLTP.multiRandomRead"),
+		wf.runner.test(wf.atomicSwap, "OLTP.atomicSwap"),
+		prepared(wf.loadUsers, wf.runner.test(wf.accountStatement, "OLTP.accountStatement")),
+		prepared(wf.loadUsers, wf.runner.test(wf.userWithTransactions, "OLTP.userWithTransactions")),
+		wf.withSenders(wf.runner.testReturns(wf.blindWrite, wf.written, "OLTP.blindWrite")),
+		wf.runner.testWith(wf.delete, wf.written, "OLTP.delete"),
 	}
-	if err := wf.runner.runTest(wf.multiRandomRead, "OLTP.multiRandomRead"); err != nil {
-		return err
+	if wf.config.History != nil {
+		tests = append(tests, wf.runner.registerTest(register{read: wf.readRegister, write: wf.writeRegister}))
 	}
-	if err := wf.runner.runTest(wf.atomicSwap, "OLTP.atomicSwap"); err != nil {
-		return err
+	if wf.config.HotRows > 0 {
+		tests = append(tests, wf.runner.test(wf.contendedSwap, contendedSwapMetricName))
 	}
-	keys, err := wf.runner.runTestReturns(wf.blindWrite, "OLTP.blindWrite")
-	if err != nil {
-		return err
+	for _, path := range writePaths(wf.config.Schema) {
+		tests = append(tests, wf.withSenders(wf.runner.test(wf.writeThrough(path), "OLTP.writePath."+path.name())))
 	}
-	if err := wf.runner.runTestWith(wf.delete, keys, "OLTP.delete"); err != nil {
-		return err
+	for _, bound := range staleReadBounds(wf.config.Staleness) {
+		stale := wf.withBound(bound)
+		tests = append(tests,
+			wf.runner.test(stale.simpleRandomReadRow, "OLTP.simpleRandomReadRow."+bound.name),
+			wf.runner.test(stale.multiSequentialRead, "OLTP.multiSequentialRead."+bound.name))
 	}
-	return nil
+	return tests
 }
 
-// Read a single row using ReadRow.
-func (wf *OLTPSpanner) simpleRandomReadRow(r *rand.Rand) error {
-	readID := datagen.RandomGeneratedTransactionID(r)
-	row, err := wf.client.Single().ReadRow(
-		wf.ctx,
-		datagen.TransactionTableName,
-		spanner.Key{readID},
+// RunMix executes a single mixed workload that interleaves the test workflows according to the
+// configured mix weights.
+//
+// YCSB updates and read-modify-writes both map to atomicSwap, since every update to a transaction
+// needs to read the row first in order to preserve referential integrity.
+func (wf *OLTPSpanner) RunMix() error {
+	if !wf.config.Selected(mixMetricName) {
+		return nil
+	}
+	ops := []mixOperation{
+		{name: "simpleRandomReadRow", testFunc: wf.simpleRandomReadRow},
+		{name: "simpleRandomQuery", testFunc: wf.simpleRandomQuery},
+		{name: "multiSequentialRead", testFunc: wf.multiSequentialRead},
+		{name: "multiRandomRead", testFunc: wf.multiRandomRead},
+		{name: "atomicSwap", testFunc: wf.atomicSwap},
+	}
+	ops = append(ops, mixKeyOperations("blindWrite", wf.blindWrite, "delete", wf.delete)...)
+	roles := map[string]string{
+		ycsbRead:            "simpleRandomReadRow",
+		ycsbUpdate:          "atomicSwap",
+		ycsbInsert:          "blindWrite",
+		ycsbScan:            "multiSequentialRead",
+		ycsbReadModifyWrite: "atomicSwap",
+	}
+	return wf.runner.runMix(ops, roles, mixMetricName)
+}
+
+// Read a single row by ID using Read, through the ID index if the schema variant has one.
+func (wf *OLTPSpanner) simpleRandomReadRow(ctx context.Context, r *rand.Rand) error {
+	readID := datagen.ChooseTransactionID(r, wf.config.Keys)
+	row, err := readTransactionByID(
+		ctx,
+		wf.single(),
+		wf.config.Schema,
+		readID,
 		[]string{datagen.TransactionFromUserColumn, datagen.TransactionToUserColumn})
 	if err != nil {
 		return err
@@ -79,17 +175,17 @@ func (wf *OLTPSpanner) simpleRandomReadRow(r *rand.Rand) error {
 }
 
 // Read a single row using the Query and DML.
-func (wf *OLTPSpanner) simpleRandomQuery(r *rand.Rand) error {
-	readID := datagen.RandomGeneratedTransactionID(r)
+func (wf *OLTPSpanner) simpleRandomQuery(ctx context.Context, r *rand.Rand) error {
+	readID := datagen.ChooseTransactionID(r, wf.config.Keys)
 	stmt := spanner.Statement{
-		SQL: `SELECT t.FromUserId, t.ToUserId
-				FROM Transactions t
-				WHERE t.Id = @id`,
+		SQL: fmt.Sprintf(`SELECT t.FromUserId, t.ToUserId
+				FROM Transactions%s t
+				WHERE t.Id = @id`, wf.config.Schema.TransactionIDHint()),
 		Params: map[string]interface{}{
 			"id": readID,
 		},
 	}
-	iter := wf.client.Single().Query(wf.ctx, stmt)
+	iter := wf.single().Query(ctx, stmt)
 	defer iter.Stop()
 	if err := wf.scanIterator(iter); err != nil {
 		return err
@@ -97,19 +193,23 @@ func (wf *OLTPSpanner) simpleRandomQuery(r *rand.Rand) error {
 	return nil
 }
 
-// Read multiple rows using a sequential Read.
-func (wf *OLTPSpanner) multiSequentialRead(r *rand.Rand) error {
-	const numReads = 100
+// Read multiple rows with sequential IDs using a Read, through the ID index if the schema variant
+// has one.
+func (wf *OLTPSpanner) multiSequentialRead(ctx context.Context, r *rand.Rand) error {
+	numReads := wf.config.numReads(100)
 
-	startReadID, endReadID := datagen.RandomGeneratedTransactionIDRange(r, numReads)
-	iter := wf.client.Single().Read(
-		wf.ctx,
-		datagen.TransactionTableName,
-		spanner.KeyRange{
-			Start: spanner.Key{startReadID},
-			End:   spanner.Key{endReadID},
-		},
-		[]string{datagen.TransactionFromUserColumn, datagen.TransactionToUserColumn})
+	startReadID, endReadID := datagen.ChooseTransactionIDRange(r, wf.config.Keys, int64(numReads))
+	keys := spanner.KeyRange{
+		Start: spanner.Key{startReadID},
+		End:   spanner.Key{endReadID},
+	}
+	iter := readTransactionsByID(
+		ctx,
+		wf.single(),
+		wf.config.Schema,
+		keys,
+		[]string{datagen.TransactionFromUserColumn, datagen.TransactionToUserColumn},
+		0)
 	defer iter.Stop()
 	if err := wf.scanIterator(iter); err != nil {
 		return err
@@ -118,24 +218,24 @@ func (wf *OLTPSpanner) multiSequentialRead(r *rand.Rand) error {
 }
 
 // Read multiple rows using a random Read.
-func (wf *OLTPSpanner) multiRandomRead(r *rand.Rand) error {
-	const numReads = 5
+func (wf *OLTPSpanner) multiRandomRead(ctx context.Context, r *rand.Rand) error {
+	numReads := wf.config.numReads(5)
 
 	readIDs := []int64{}
 	for i := 0; i < numReads; i++ {
-		readID := datagen.RandomGeneratedTransactionID(r)
+		readID := datagen.ChooseTransactionID(r, wf.config.Keys)
 		readIDs = append(readIDs, readID)
 	}
 
 	stmt := spanner.Statement{
-		SQL: `SELECT t.FromUserId, t.ToUserId
-				FROM Transactions@{FORCE_INDEX=UniqueId} t
-				WHERE t.Id IN UNNEST(@keys)`,
+		SQL: fmt.Sprintf(`SELECT t.FromUserId, t.ToUserId
+				FROM Transactions%s t
+				WHERE t.Id IN UNNEST(@keys)`, wf.config.Schema.TransactionIDHint()),
 		Params: map[string]interface{}{
 			"keys": readIDs,
 		},
 	}
-	iter := wf.client.Single().Query(wf.ctx, stmt)
+	iter := wf.single().Query(ctx, stmt)
 	defer iter.Stop()
 	if err := wf.scanIterator(iter); err != nil {
 		return err
@@ -144,66 +244,355 @@ func (wf *OLTPSpanner) multiRandomRead(r *rand.Rand) error {
 }
 
 // Read and update a single row.
-func (wf *OLTPSpanner) atomicSwap(r *rand.Rand) error {
+func (wf *OLTPSpanner) atomicSwap(ctx context.Context, r *rand.Rand) error {
 	// This should be both valid and random, hence we need to know the range of valid
 	// identifiers within the table.
-	updateID := datagen.RandomGeneratedTransactionID(r)
-	_, err := wf.client.ReadWriteTransaction(wf.ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
-		row, err := txn.ReadRow(
-			wf.ctx,
-			datagen.TransactionTableName,
-			spanner.Key{updateID},
-			[]string{datagen.TransactionFromUserColumn, datagen.TransactionToUserColumn})
-		if err != nil {
-			return err
-		}
-		var fromUserID, toUserID int64
-		if err := row.Columns(&fromUserID, &toUserID); err != nil {
-			return err
-		}
+	updateID := datagen.ChooseTransactionID(r, wf.config.Keys)
+	_, err := wf.client.ReadWriteTransaction(ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+		return wf.swapUsers(ctx, txn, updateID)
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
 
-		// Swapping the user IDs guarantees that referential integrity is maintained.
-		mutation := spanner.UpdateMap(datagen.TransactionTableName, map[string]interface{}{
-			"id":         updateID,
+// Read and update one of a few hot rows, so that concurrent workers conflict with each other. The
+// sample covers every attempt up to the commit. Attempts are counted by how often Spanner runs the
+// transaction function, since it reruns the function whenever the transaction aborts.
+func (wf *OLTPSpanner) contendedSwap(ctx context.Context, r *rand.Rand) error {
+	updateID := datagen.TransactionBaseID + r.Int63n(wf.config.HotRows)
+	var attempts int64
+	_, err := wf.client.ReadWriteTransaction(ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+		attempts++
+		return wf.swapUsers(ctx, txn, updateID)
+	})
+	wf.metrics.Count(contendedSwapMetricName + " [TRANSACTIONS]")
+	wf.metrics.Add(contendedSwapMetricName+" [ATTEMPTS]", attempts)
+	if attempts > 1 {
+		wf.metrics.Add(contendedSwapMetricName+" [RETRIES]", attempts-1)
+		wf.metrics.Count(contendedSwapMetricName + " [RETRIED]")
+	}
+	return err
+}
+
+// swapUsers swaps the sender and the receiver of a transaction within txn, and moves its time to
+// the commit timestamp. Swapping the user IDs guarantees that referential integrity is maintained.
+//
+// The row is found through the ID index if the schema variant has one. When the sender or the time
+// is part of the primary key, the row moves to a new key, so it is deleted and inserted again under
+// the new key. Otherwise, it is updated in place by its primary key.
+func (wf *OLTPSpanner) swapUsers(ctx context.Context, txn *spanner.ReadWriteTransaction, id int64) error {
+	columns := append(append([]string{}, transactionKeyColumnsSpanner...),
+		datagen.TransactionCompanyColumn,
+		datagen.TransactionToUserColumn,
+		datagen.TransactionRevisionColumn)
+	row, err := readTransactionByID(ctx, txn, wf.config.Schema, id, columns)
+	if err != nil {
+		return err
+	}
+	var fromUserID, companyID, toUserID int64
+	var ts time.Time
+	var revision spanner.NullInt64
+	if err := row.Columns(&id, &fromUserID, &ts, &companyID, &toUserID, &revision); err != nil {
+		return err
+	}
+	key := wf.config.Schema.TransactionKey(id, fromUserID, ts)
+
+	if !isTransactionKeyColumn(wf.config.Schema, datagen.TransactionFromUserColumn) &&
+		!isTransactionKeyColumn(wf.config.Schema, "Time") {
+		mutation := transactionUpdate(
+			wf.config.Schema,
+			key,
+			[]string{datagen.TransactionFromUserColumn, datagen.TransactionToUserColumn, "Time"},
+			[]interface{}{toUserID, fromUserID, spanner.CommitTimestamp})
+		return txn.BufferWrite([]*spanner.Mutation{mutation})
+	}
+	return txn.BufferWrite([]*spanner.Mutation{
+		spanner.Delete(datagen.TransactionTableName, key),
+		spanner.InsertMap(datagen.TransactionTableName, map[string]interface{}{
+			"id":         id,
+			"companyId":  companyID,
 			"fromUserId": toUserID,
 			"toUserId":   fromUserID,
 			"time":       spanner.CommitTimestamp,
-		})
-		return txn.BufferWrite([]*spanner.Mutation{mutation})
+			"revision":   revision,
+		}),
 	})
+}
+
+// readRegister reads the value of a register with a strong read.
+func (wf *OLTPSpanner) readRegister(ctx context.Context, id int64) (string, error) {
+	row, err := wf.client.Single().ReadRow(
+		ctx,
+		datagen.RegisterTableName,
+		spanner.Key{id},
+		[]string{datagen.RegisterValueColumn})
+	if err != nil {
+		return "", err
+	}
+	var value string
+	err = row.Columns(&value)
+	return value, err
+}
+
+// writeRegister blindly replaces the value of a register.
+func (wf *OLTPSpanner) writeRegister(ctx context.Context, id int64, value string) error {
+	mutation := spanner.InsertOrUpdate(
+		datagen.RegisterTableName,
+		[]string{"Id", datagen.RegisterValueColumn},
+		[]interface{}{id, value})
+	_, err := wf.client.Apply(ctx, []*spanner.Mutation{mutation})
+	return err
+}
+
+// Read an account statement for a single user within a ReadOnlyTransaction, so that every step
+// reads from the same consistent snapshot. The statement consists of the most recent transactions
+// sent by the user, followed by the Users and Companies rows that those transactions reference.
+func (wf *OLTPSpanner) accountStatement(ctx context.Context, r *rand.Rand) error {
+	userID := wf.users.keys[r.Int63n(int64(len(wf.users.keys)))]
+	txn := wf.client.ReadOnlyTransaction()
+	defer txn.Close()
+	return wf.readStatement(ctx, snapshotStatementReader{txn: txn}, userID)
+}
+
+// readStatement reads the account statement of a user through reader, timing each step under its
+// own metric.
+func (wf *OLTPSpanner) readStatement(ctx context.Context, reader statementReader, userID int64) error {
+	numReads := wf.config.numReads(20)
+
+	start := time.Now()
+	txns, err := reader.transactions(ctx, accountStatementQuery(userID, numReads))
 	if err != nil {
 		return err
 	}
+	wf.metrics.Track(start, "OLTP.accountStatement.Transactions")
+
+	userKeys, companyKeys := accountStatementKeys(userID, txns)
+	start = time.Now()
+	if err := reader.names(ctx, datagen.UserTableName, userKeys, datagen.UserNameColumn); err != nil {
+		return err
+	}
+	wf.metrics.Track(start, "OLTP.accountStatement.Users")
+
+	start = time.Now()
+	if err := reader.names(ctx, datagen.CompanyTableName, companyKeys, datagen.CompanyNameColumn); err != nil {
+		return err
+	}
+	wf.metrics.Track(start, "OLTP.accountStatement.Companies")
 	return nil
 }
 
+// accountStatementQuery selects the most recent transactions sent by a user.
+func accountStatementQuery(userID int64, numReads int) spanner.Statement {
+	return spanner.Statement{
+		SQL: `SELECT t.CompanyId, t.FromUserId, t.ToUserId
+				FROM Transactions t
+				WHERE t.FromUserId = @id
+				ORDER BY t.Time DESC
+				LIMIT @limit`,
+		Params: map[string]interface{}{
+			"id":    userID,
+			"limit": int64(numReads),
+		},
+	}
+}
+
+// accountStatementKeys returns the keys of the users and companies that an account statement
+// references. The users are the owner of the statement, followed by the receiver of every
+// transaction. Users are keyed by their ID alone with every schema variant, under whichever column
+// name the variant gives it.
+func accountStatementKeys(userID int64, txns []Transaction) ([]spanner.Key, []spanner.Key) {
+	userKeys := []spanner.Key{{userID}}
+	companyKeys := []spanner.Key{}
+	for _, txn := range txns {
+		userKeys = append(userKeys, spanner.Key{txn.ToUserID})
+		companyKeys = append(companyKeys, spanner.Key{txn.CompanyID})
+	}
+	return userKeys, companyKeys
+}
+
+// statementReader reads the steps of an account statement.
+type statementReader interface {
+	// transactions returns the company, sender and receiver of every transaction that stmt
+	// selects.
+	transactions(ctx context.Context, stmt spanner.Statement) ([]Transaction, error)
+	// names reads the name column of the rows of table with the given keys.
+	names(ctx context.Context, table string, keys []spanner.Key, column string) error
+}
+
+// snapshotStatementReader reads every step of an account statement from the snapshot of a single
+// read-only transaction.
+type snapshotStatementReader struct {
+	txn *spanner.ReadOnlyTransaction
+}
+
+func (s snapshotStatementReader) transactions(ctx context.Context, stmt spanner.Statement) ([]Transaction, error) {
+	iter := s.txn.Query(ctx, stmt)
+	defer iter.Stop()
+	txns := []Transaction{}
+	for {
+		row, err := iter.Next()
+		if err != nil {
+			if err == iterator.Done {
+				break
+			}
+			return nil, err
+		}
+		var txn Transaction
+		if err := row.Columns(&txn.CompanyID, &txn.FromUserID, &txn.ToUserID); err != nil {
+			return nil, err
+		}
+		txns = append(txns, txn)
+	}
+	return txns, nil
+}
+
+func (s snapshotStatementReader) names(ctx context.Context, table string, keys []spanner.Key, column string) error {
+	keySets := []spanner.KeySet{}
+	for _, key := range keys {
+		keySets = append(keySets, key)
+	}
+	iter := s.txn.Read(ctx, table, spanner.KeySets(keySets...), []string{column})
+	defer iter.Stop()
+	var name string
+	for {
+		row, err := iter.Next()
+		if err != nil {
+			if err == iterator.Done {
+				break
+			}
+			return err
+		}
+		if err := row.Columns(&name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Read a single user along with their most recent transactions, using a join between the parent
+// row and its children. When transactions are interleaved in users, both sides of the join are
+// stored together, so this can be compared directly against the flat schema variants.
+func (wf *OLTPSpanner) userWithTransactions(ctx context.Context, r *rand.Rand) error {
+	numReads := wf.config.numReads(20)
+
+	userID := wf.users.keys[r.Int63n(int64(len(wf.users.keys)))]
+	stmt := spanner.Statement{
+		SQL: fmt.Sprintf(`SELECT u.Name, t.Id, t.Time
+				FROM Users u
+				JOIN Transactions t ON t.FromUserId = u.%s
+				WHERE u.%s = @id
+				ORDER BY t.Time DESC
+				LIMIT @limit`, wf.config.Schema.UserIDColumn, wf.config.Schema.UserIDColumn),
+		Params: map[string]interface{}{
+			"id":    userID,
+			"limit": int64(numReads),
+		},
+	}
+	iter := wf.single().Query(ctx, stmt)
+	defer iter.Stop()
+	var name string
+	var id int64
+	var ts time.Time
+	for {
+		row, err := iter.Next()
+		if err != nil {
+			if err == iterator.Done {
+				break
+			}
+			return err
+		}
+		if err := row.Columns(&name, &id, &ts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Insert, update and then delete a single row through a write path, so that each path pays for
+// the same changes. The update and the delete address the row by the primary key that the insert
+// returns. Every change is also reported as its own metric.
+func (wf *OLTPSpanner) writeThrough(path writePath) func(ctx context.Context, r *rand.Rand) error {
+	metricName := "OLTP.writePath." + path.name()
+	return func(ctx context.Context, r *rand.Rand) error {
+		// For these tests, referential integrity is un-important since there are no defined
+		// foreign key constraints.
+		txn := Transaction{
+			ID:         r.Int63(),
+			CompanyID:  r.Int63(),
+			FromUserID: wf.sender(r),
+			ToUserID:   r.Int63(),
+		}
+
+		start := time.Now()
+		key, err := path.insert(ctx, wf.client, txn)
+		if err != nil {
+			return err
+		}
+		wf.metrics.Track(start, metricName+".insert")
+
+		start = time.Now()
+		if err := path.update(ctx, wf.client, key, r.Int63()); err != nil {
+			return err
+		}
+		wf.metrics.Track(start, metricName+".update")
+
+		start = time.Now()
+		if err := path.delete(ctx, wf.client, key); err != nil {
+			return err
+		}
+		wf.metrics.Track(start, metricName+".delete")
+		return nil
+	}
+}
+
 // Blindly write a single row.
-func (wf *OLTPSpanner) blindWrite(r *rand.Rand) (int64, error) {
+func (wf *OLTPSpanner) blindWrite(ctx context.Context, r *rand.Rand) (int64, error) {
 	// For these tests, referential integrity is un-important since there are no defined
 	// foreign key constraints.
 	addID := r.Int63()
 	mutation := spanner.InsertMap(datagen.TransactionTableName, map[string]interface{}{
 		"id":         addID,
 		"companyId":  r.Int63(),
-		"fromUserId": r.Int63(),
+		"fromUserId": wf.sender(r),
 		"toUserId":   r.Int63(),
 		"time":       spanner.CommitTimestamp,
 	})
-	_, err := wf.client.Apply(wf.ctx, []*spanner.Mutation{mutation})
+	_, err := wf.client.Apply(ctx, []*spanner.Mutation{mutation})
 	if err != nil {
 		return 0, err
 	}
 	return addID, nil
 }
 
-// Delete a predefined row.
-func (wf *OLTPSpanner) delete(r *rand.Rand, key int64) error {
-	mutation := spanner.Delete(datagen.TransactionTableName, spanner.Key{key})
-	_, err := wf.client.Apply(wf.ctx, []*spanner.Mutation{mutation})
-	if err != nil {
-		return err
+// Delete a predefined row by ID. When the ID is not the primary key, the full primary key is looked
+// up through the ID index within the same ReadWriteTransaction.
+func (wf *OLTPSpanner) delete(ctx context.Context, r *rand.Rand, key int64) error {
+	return deleteTransactionByID(ctx, wf.client, wf.config.Schema, key)
+}
+
+// withSenders returns a test that loads users before it runs, if the test writes transactions that
+// need an existing sender.
+func (wf *OLTPSpanner) withSenders(t test) test {
+	if !wf.config.Schema.Interleaved {
+		return t
 	}
-	return nil
+	return prepared(wf.loadUsers, t)
+}
+
+// sender returns the sender of a new transaction. An interleaved transaction can only be written
+// under an existing user, so the sender is then chosen from the loaded users.
+func (wf *OLTPSpanner) sender(r *rand.Rand) int64 {
+	if !wf.config.Schema.Interleaved {
+		return r.Int63()
+	}
+	return wf.users.keys[r.Int63n(int64(len(wf.users.keys)))]
+}
+
+// loadUsers reads every user ID, for tests that need a valid user as input.
+func (wf *OLTPSpanner) loadUsers() error {
+	return loadTableIDs(wf.ctx, wf.client, wf.users, datagen.UserTableName, wf.config.Schema.UserIDColumn)
 }
 
 func (wf *OLTPSpanner) scanIterator(iter *spanner.RowIterator) error {
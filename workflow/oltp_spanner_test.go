@@ -0,0 +1,139 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/r7wang/gcloud-test/datagen"
+	"github.com/r7wang/gcloud-test/timer"
+)
+
+// nameRead is a single call to statementReader.names.
+type nameRead struct {
+	table  string
+	keys   []spanner.Key
+	column string
+}
+
+// fakeStatementReader returns fixed transactions and records every read. The name read of
+// failTable fails.
+type fakeStatementReader struct {
+	txns      []Transaction
+	failTable string
+	stmts     []spanner.Statement
+	nameReads []nameRead
+}
+
+func (f *fakeStatementReader) transactions(ctx context.Context, stmt spanner.Statement) ([]Transaction, error) {
+	f.stmts = append(f.stmts, stmt)
+	return f.txns, nil
+}
+
+func (f *fakeStatementReader) names(ctx context.Context, table string, keys []spanner.Key, column string) error {
+	f.nameReads = append(f.nameReads, nameRead{table: table, keys: keys, column: column})
+	if table == f.failTable {
+		return errors.New("read failed")
+	}
+	return nil
+}
+
+// trackedSamples returns the number of successful samples tracked under each of names.
+func trackedSamples(t *testing.T, metrics *timer.Metrics, names []string) []int64 {
+	t.Helper()
+	samples := []int64{}
+	for _, name := range names {
+		result, err := metrics.StepResult(name, 0, time.Second)
+		if err != nil {
+			t.Fatalf("StepResult(%q) returned error: %v", name, err)
+		}
+		samples = append(samples, result.Successes)
+	}
+	return samples
+}
+
+// statementRuns is the number of statements read by each test, which StepResult needs to be more
+// than one to compute a 99th percentile.
+const statementRuns = 3
+
+var accountStatementMetrics = []string{
+	"OLTP.accountStatement.Transactions",
+	"OLTP.accountStatement.Users",
+	"OLTP.accountStatement.Companies",
+}
+
+func TestReadStatement(t *testing.T) {
+	txns := []Transaction{
+		{CompanyID: 10, FromUserID: 1, ToUserID: 2},
+		{CompanyID: 11, FromUserID: 1, ToUserID: 3},
+	}
+	wantStmt := accountStatementQuery(1, 20)
+	wantNameReads := []nameRead{
+		{
+			table:  datagen.UserTableName,
+			keys:   []spanner.Key{{int64(1)}, {int64(2)}, {int64(3)}},
+			column: datagen.UserNameColumn,
+		},
+		{
+			table:  datagen.CompanyTableName,
+			keys:   []spanner.Key{{int64(10)}, {int64(11)}},
+			column: datagen.CompanyNameColumn,
+		},
+	}
+	for _, name := range []string{"key-id", "key-from-user-and-time", "interleaved"} {
+		schema, err := datagen.LookupSpannerSchema(name)
+		if err != nil {
+			t.Fatalf("LookupSpannerSchema(%q) returned error: %v", name, err)
+		}
+		config := DefaultConfig()
+		config.Schema = schema
+		metrics := timer.NewMetrics()
+		wf := NewOLTPSpanner(context.Background(), nil, metrics, config)
+		reader := &fakeStatementReader{txns: txns}
+
+		for i := 0; i < statementRuns; i++ {
+			if err := wf.readStatement(context.Background(), reader, 1); err != nil {
+				t.Fatalf("%s: readStatement() returned error: %v", name, err)
+			}
+		}
+		if !reflect.DeepEqual(reader.stmts[0], wantStmt) {
+			t.Errorf("%s: readStatement() queried %v, want %v", name, reader.stmts[0], wantStmt)
+		}
+		if !reflect.DeepEqual(reader.nameReads[:2], wantNameReads) {
+			t.Errorf("%s: readStatement() read names %v, want %v", name, reader.nameReads, wantNameReads)
+		}
+		if got, want := trackedSamples(t, metrics, accountStatementMetrics), []int64{statementRuns, statementRuns, statementRuns}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: readStatement() tracked %v samples of %v, want %v", name, got, accountStatementMetrics, want)
+		}
+	}
+}
+
+func TestReadStatementFailedStep(t *testing.T) {
+	metrics := timer.NewMetrics()
+	wf := NewOLTPSpanner(context.Background(), nil, metrics, DefaultConfig())
+	reader := &fakeStatementReader{failTable: datagen.UserTableName}
+
+	for i := 0; i < statementRuns; i++ {
+		if err := wf.readStatement(context.Background(), reader, 1); err == nil {
+			t.Fatal("readStatement() succeeded, want an error")
+		}
+	}
+	if len(reader.nameReads) != statementRuns {
+		t.Errorf("readStatement() read names %d times, want %d", len(reader.nameReads), statementRuns)
+	}
+	// A step that fails is not timed, and the steps after it do not run.
+	if got, want := trackedSamples(t, metrics, accountStatementMetrics), []int64{statementRuns, 0, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("readStatement() tracked %v samples of %v, want %v", got, accountStatementMetrics, want)
+	}
+}
+
+func TestAccountStatementQuery(t *testing.T) {
+	stmt := accountStatementQuery(7, 5)
+	wantParams := map[string]interface{}{"id": int64(7), "limit": int64(5)}
+	if !reflect.DeepEqual(stmt.Params, wantParams) {
+		t.Errorf("accountStatementQuery() params = %v, want %v", stmt.Params, wantParams)
+	}
+}
@@ -0,0 +1,64 @@
+package workflow
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/r7wang/gcloud-test/datagen"
+	"github.com/r7wang/gcloud-test/history"
+)
+
+// registerMetricName names the register test of every suite that has one.
+const registerMetricName = "OLTP.register"
+
+// register reads and writes a single value per row of the registers table, which the register test
+// treats as an independent read/write register.
+type register struct {
+	read  func(ctx context.Context, id int64) (string, error)
+	write func(ctx context.Context, id int64, value string) error
+}
+
+// registerTest returns a test that randomly reads and writes registers 0 to HistoryKeys-1 through
+// reg, recording every operation to the configured history. Each register is written before the
+// test starts, so that the history begins with a known value for every key.
+//
+// Reads that fail are recorded as failed, since they observed nothing. Writes that fail are
+// recorded as indeterminate, since an error does not prove that the write was not applied.
+func (r *runner) registerTest(reg register) test {
+	rec := r.config.History
+	numKeys := r.config.HistoryKeys
+	initialize := func() error {
+		initRand := datagen.NewRand(r.config.Seed, registerMetricName+".initialize")
+		for id := int64(0); id < numKeys; id++ {
+			value := datagen.Int64String(initRand.Int63())
+			start := time.Now()
+			if err := reg.write(r.ctx, id, value); err != nil {
+				return err
+			}
+			rec.Record(datagen.Int64String(id), history.Write, value, start, history.OK)
+		}
+		return nil
+	}
+	return prepared(initialize, r.test(func(ctx context.Context, rnd *rand.Rand) error {
+		id := rnd.Int63n(numKeys)
+		key := datagen.Int64String(id)
+		start := time.Now()
+		if rnd.Intn(2) == 0 {
+			value, err := reg.read(ctx, id)
+			if err != nil {
+				rec.Record(key, history.Read, "", start, history.Fail)
+				return err
+			}
+			rec.Record(key, history.Read, value, start, history.OK)
+			return nil
+		}
+		value := datagen.Int64String(rnd.Int63())
+		if err := reg.write(ctx, id, value); err != nil {
+			rec.Record(key, history.Write, value, start, history.Info)
+			return err
+		}
+		rec.Record(key, history.Write, value, start, history.OK)
+		return nil
+	}, registerMetricName))
+}
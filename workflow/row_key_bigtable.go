@@ -0,0 +1,107 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"cloud.google.com/go/bigtable"
+	"github.com/r7wang/gcloud-test/datagen"
+)
+
+// rowKeySampleSize is the approximate number of row keys sampled from the transactions table, when
+// the row key strategy cannot build keys from transaction IDs.
+const rowKeySampleSize = 100000
+
+// transactionRowKeys finds the row keys of the transactions that the tests pick. Row keys are
+// built from transaction IDs when the strategy allows it. Otherwise, the picked transactions wrap
+// around a sample of row keys that is loaded before the tests start, so the key distribution still
+// decides which of the sampled rows are hot.
+type transactionRowKeys struct {
+	strategy datagen.RowKeyStrategy
+	sample   []string
+}
+
+// key returns the row key of a transaction that was picked by ID.
+func (k *transactionRowKeys) key(id int64) string {
+	if k.strategy.Addressable() {
+		return k.strategy.Encode(datagen.TransactionKey{ID: id})
+	}
+	return k.sample[(id-datagen.TransactionBaseID)%int64(len(k.sample))]
+}
+
+// load samples row keys from the transactions table, if the strategy needs them.
+func (k *transactionRowKeys) load(ctx context.Context, client *bigtable.Client) error {
+	if k.strategy.Addressable() || len(k.sample) > 0 {
+		return nil
+	}
+	filter := bigtable.ChainFilters(
+		bigtable.RowSampleFilter(float64(rowKeySampleSize)/float64(datagen.TransactionCount)),
+		bigtable.CellsPerRowLimitFilter(1),
+		bigtable.StripValueFilter())
+	sample := []string{}
+	table := client.Open(datagen.TransactionTableName)
+	err := table.ReadRows(ctx, bigtable.InfiniteRange(""), func(row bigtable.Row) bool {
+		sample = append(sample, row.Key())
+		return true
+	}, bigtable.RowFilter(filter))
+	if err != nil {
+		return err
+	}
+	if len(sample) == 0 {
+		return fmt.Errorf("No rows found in %s", datagen.TransactionTableName)
+	}
+	k.sample = sample
+	return nil
+}
+
+// rowKeyStore carries row keys loaded before a test over to the test itself.
+type rowKeyStore struct {
+	keys []string
+}
+
+// loadTableRowKeys reads every row key of a table into store, for tests that need a valid row as
+// input. The row keys are only read once, by the first test that needs them.
+func loadTableRowKeys(ctx context.Context, client *bigtable.Client, store *rowKeyStore, tableName string) error {
+	if len(store.keys) > 0 {
+		return nil
+	}
+	rowKeys := []string{}
+	table := client.Open(tableName)
+	err := table.ReadRows(ctx, bigtable.InfiniteRange(""), func(row bigtable.Row) bool {
+		rowKeys = append(rowKeys, row.Key())
+		return true
+	}, bigtable.RowFilter(bigtable.ChainFilters(bigtable.CellsPerRowLimitFilter(1), bigtable.StripValueFilter())))
+	if err != nil {
+		return err
+	}
+	if len(rowKeys) == 0 {
+		return fmt.Errorf("No rows found in %s", tableName)
+	}
+	store.keys = rowKeys
+	return nil
+}
+
+// writtenTransaction returns the fields of a transaction written by a test, derived from its ID
+// alone. A later test that only holds the ID can then build the same row key with any strategy.
+func writtenTransaction(id int64) datagen.TransactionKey {
+	const timeRange = datagen.TransactionMaxTime - datagen.TransactionMinTime
+
+	r := rand.New(rand.NewSource(id))
+	return datagen.TransactionKey{
+		ID:         id,
+		CompanyID:  datagen.Int64String(r.Int63()),
+		FromUserID: datagen.Int64String(r.Int63()),
+		Time:       time.Unix(datagen.TransactionMinTime+r.Int63n(timeRange), 0),
+	}
+}
+
+// keysCompany returns whether the row key of a transaction depends on its company, in which case
+// changing the company of a transaction also changes its row key.
+func keysCompany(strategy datagen.RowKeyStrategy) bool {
+	key := writtenTransaction(datagen.TransactionBaseID)
+	retagged := key
+	retagged.CompanyID = key.CompanyID + "0"
+	return strategy.Encode(key) != strategy.Encode(retagged)
+}
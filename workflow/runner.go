@@ -1,62 +1,292 @@
 package workflow
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/r7wang/gcloud-test/datagen"
 	"github.com/r7wang/gcloud-test/timer"
 )
 
 // runner provides common tools for running tests.
 type runner struct {
+	ctx     context.Context
 	metrics *timer.Metrics
+	config  Config
 }
 
-// newRunner returns a new Runner instance.
-func newRunner(metrics *timer.Metrics) *runner {
-	return &runner{metrics: metrics}
+// newRunner returns a new Runner instance. Every sample runs with a context derived from ctx.
+func newRunner(ctx context.Context, metrics *timer.Metrics, config Config) *runner {
+	return &runner{ctx: ctx, metrics: metrics, config: config}
 }
 
-func (r *runner) runTest(testFunc func(r *rand.Rand) error, metricName string) error {
-	defer r.metrics.Track(time.Now(), fmt.Sprintf("%s [ALL]", metricName))
-	randSeeded := rand.New(rand.NewSource(rand.Int63()))
-	for i := 0; i < NumSamples; i++ {
-		start := time.Now()
-		if err := testFunc(randSeeded); err != nil {
-			return err
-		}
-		r.metrics.Track(start, metricName)
-	}
-	return nil
+func (r *runner) runTest(testFunc func(ctx context.Context, r *rand.Rand) error, metricName string) error {
+	return r.runLoad(metricName, func(ctx context.Context, randSeeded *rand.Rand, sample int, start time.Time) error {
+		return testFunc(ctx, randSeeded)
+	})
 }
 
-func (r *runner) runTestReturns(testFunc func(r *rand.Rand) (int64, error), metricName string) ([]int64, error) {
-	defer r.metrics.Track(time.Now(), fmt.Sprintf("%s [ALL]", metricName))
-	randSeeded := rand.New(rand.NewSource(rand.Int63()))
+func (r *runner) runTestReturns(testFunc func(ctx context.Context, r *rand.Rand) (int64, error), metricName string) ([]int64, error) {
+	var mu sync.Mutex
 	keys := []int64{}
-	for i := 0; i < NumSamples; i++ {
-		start := time.Now()
-		key, err := testFunc(randSeeded)
+	err := r.runLoad(metricName, func(ctx context.Context, randSeeded *rand.Rand, sample int, start time.Time) error {
+		key, err := testFunc(ctx, randSeeded)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		r.metrics.Track(start, metricName)
+		mu.Lock()
 		keys = append(keys, key)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 	return keys, nil
 }
 
-func (r *runner) runTestWith(testFunc func(r *rand.Rand, key int64) error, keys []int64, metricName string) error {
-	// We may want to assert that keys has a length of NumSamples.
-	defer r.metrics.Track(time.Now(), fmt.Sprintf("%s [ALL]", metricName))
-	randSeeded := rand.New(rand.NewSource(rand.Int63()))
-	for _, key := range keys {
-		start := time.Now()
-		if err := testFunc(randSeeded, key); err != nil {
+func (r *runner) runTestWith(testFunc func(ctx context.Context, r *rand.Rand, key int64) error, keys []int64, metricName string) error {
+	// Every key is visited regardless of the configured duration, since the keys usually refer to
+	// rows that were written by an earlier test and need to be cleaned up.
+	return r.run(budget{samples: int64(len(keys))}, metricName, func(ctx context.Context, randSeeded *rand.Rand, sample int, start time.Time) error {
+		return testFunc(ctx, randSeeded, keys[sample])
+	})
+}
+
+// runMix runs a mixed workload in which every sample picks one of the operations according to the
+// configured mix weights. Each sample is tracked under metricName for the mix as a whole, and
+// under the name of the picked operation, prefixed by metricName. Every operation is cleaned up
+// once the workload ends, even if it failed.
+func (r *runner) runMix(ops []mixOperation, roles map[string]string, metricName string) error {
+	weighted, err := resolveMix(r.config.Mix, ops, roles)
+	if err != nil {
+		return err
+	}
+	err = r.runLoad(metricName, func(ctx context.Context, randSeeded *rand.Rand, sample int, start time.Time) error {
+		for {
+			op, err := pickOperation(randSeeded, weighted)
+			if err != nil {
+				return err
+			}
+			err = op.testFunc(ctx, randSeeded)
+			if err == errMixSkip {
+				continue
+			}
+			if err != nil {
+				r.metrics.TrackError(fmt.Sprintf("%s.%s", metricName, op.name), sampleErrorClass(ctx, err))
+				return err
+			}
+			r.metrics.Track(start, fmt.Sprintf("%s.%s", metricName, op.name))
+			return nil
+		}
+	})
+	for _, op := range ops {
+		if op.cleanup == nil {
+			continue
+		}
+		if cleanupErr := op.cleanup(r.ctx, rand.New(rand.NewSource(r.config.Seed))); cleanupErr != nil && err == nil {
+			err = cleanupErr
+		}
+	}
+	return err
+}
+
+// runLoad runs a test with the configured load, either as a single run or as a step-load run.
+func (r *runner) runLoad(metricName string, sampleFunc func(ctx context.Context, r *rand.Rand, sample int, start time.Time) error) error {
+	if r.config.Keys != nil {
+		r.config.Keys.Reset()
+	}
+	if r.config.StepQPS > 0 {
+		return r.runSteps(metricName, sampleFunc)
+	}
+	return r.run(r.budget(), metricName, sampleFunc)
+}
+
+// runSteps searches for the load at which a test saturates. It ramps the offered load step by
+// step, holding each step for a fixed time, and records the throughput and latency percentiles of
+// each step. The search ends at the first step that breaks the latency SLO or the error threshold.
+//
+// Each step tracks its samples under its own metric name, so that its percentiles are not mixed
+// with those of other steps. Errors never stop a step early, since they count towards the error
+// threshold instead.
+func (r *runner) runSteps(metricName string, sampleFunc func(ctx context.Context, r *rand.Rand, sample int, start time.Time) error) error {
+	if r.config.StepSLO <= 0 && r.config.StepMaxQPS <= 0 {
+		return fmt.Errorf("Step-load run of %s needs either an SLO or a maximum QPS", metricName)
+	}
+	increment := r.config.StepIncrementQPS
+	if increment <= 0 {
+		increment = r.config.StepQPS
+	}
+
+	for qps := r.config.StepQPS; r.config.StepMaxQPS <= 0 || qps <= r.config.StepMaxQPS; qps += increment {
+		step := *r
+		step.config.TargetQPS = qps
+		step.config.Duration = r.config.StepDuration
+		step.config.ErrorBudget = -1
+		stepName := fmt.Sprintf("%s [%.2fqps]", metricName, qps)
+
+		begin := time.Now()
+		if err := step.run(step.budget(), stepName, sampleFunc); err != nil {
+			return err
+		}
+		result, err := r.metrics.StepResult(stepName, qps, time.Since(begin))
+		if err != nil {
 			return err
 		}
-		r.metrics.Track(start, metricName)
+		r.metrics.RecordStep(metricName, result)
+
+		if r.config.StepSLO > 0 && (result.Successes == 0 || result.Pct99 > r.config.StepSLO) {
+			break
+		}
+		if result.ErrorRate() > r.config.StepMaxErrorRate {
+			break
+		}
 	}
 	return nil
 }
+
+// budget returns the sample budget for a test, based on the configured sample count or duration.
+func (r *runner) budget() budget {
+	if r.config.Duration > 0 {
+		return budget{samples: -1, deadline: time.Now().Add(r.config.Duration)}
+	}
+	return budget{samples: int64(r.config.NumSamples)}
+}
+
+// run distributes the sample budget across the configured number of workers. Each worker claims
+// the next sample index and runs it to completion before claiming another, so there are never
+// more requests in flight than there are workers. The first error stops every worker from
+// claiming further samples and is returned once all of them have finished, unless the configured
+// error budget allows the test to carry on.
+func (r *runner) run(b budget, metricName string, sampleFunc func(ctx context.Context, r *rand.Rand, sample int, start time.Time) error) error {
+	defer r.metrics.Track(time.Now(), fmt.Sprintf("%s [ALL]", metricName))
+
+	concurrency := r.config.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sched := newSchedule(r.config.TargetQPS)
+	missedName := fmt.Sprintf("%s [MISSED]", metricName)
+
+	// Each test derives its worker seeds from the run-wide seed and its own name, so that its key
+	// sequence does not depend on which tests ran before it.
+	seeds := datagen.NewRand(r.config.Seed, metricName)
+
+	var claimed int64
+	var failed int64
+	var stopped int32
+	errs := make(chan error, concurrency)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		// Each worker needs its own random source since rand.Rand is not safe for concurrent use.
+		randSeeded := rand.New(rand.NewSource(seeds.Int63()))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for atomic.LoadInt32(&stopped) == 0 {
+				sample := atomic.AddInt64(&claimed, 1) - 1
+				if !b.allows(sample) {
+					return
+				}
+				start, missed := sched.wait(sample)
+				if missed {
+					r.metrics.Count(missedName)
+				}
+				ctx, cancel := r.sampleContext()
+				err := sampleFunc(ctx, randSeeded, int(sample), start)
+				if err == nil {
+					cancel()
+					r.metrics.Track(start, metricName)
+					continue
+				}
+				r.metrics.TrackError(metricName, sampleErrorClass(ctx, err))
+				cancel()
+				if r.withinErrorBudget(atomic.AddInt64(&failed, 1)) {
+					continue
+				}
+				atomic.StoreInt32(&stopped, 1)
+				if r.config.ErrorBudget > 0 {
+					err = fmt.Errorf("%s exceeded its budget of %d errors: %v", metricName, r.config.ErrorBudget, err)
+				}
+				errs <- err
+				return
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+// sampleContext returns the context for a single sample, bounded by the configured timeout.
+func (r *runner) sampleContext() (context.Context, context.CancelFunc) {
+	if r.config.Timeout > 0 {
+		return context.WithTimeout(r.ctx, r.config.Timeout)
+	}
+	return context.WithCancel(r.ctx)
+}
+
+// withinErrorBudget returns whether a test can keep running after the given number of failures.
+func (r *runner) withinErrorBudget(failed int64) bool {
+	return r.config.ErrorBudget < 0 || failed <= int64(r.config.ErrorBudget)
+}
+
+// budget bounds the number of samples that a test runs, either by count or by wall-clock time. A
+// negative sample count leaves the number of samples unbounded.
+type budget struct {
+	samples  int64
+	deadline time.Time
+}
+
+// allows returns whether the sample at the given index should still be run.
+func (b budget) allows(sample int64) bool {
+	if b.samples >= 0 && sample >= b.samples {
+		return false
+	}
+	if !b.deadline.IsZero() && time.Now().After(b.deadline) {
+		return false
+	}
+	return true
+}
+
+// schedule assigns each sample an intended send time when running in open-loop mode.
+//
+// In closed-loop mode, a slow response delays every request queued behind it and those delays are
+// never measured, which hides tail latency (coordinated omission). Measuring latency from the
+// intended send time instead of the actual send time charges each request for the time it spent
+// waiting on an earlier one.
+type schedule struct {
+	begin    time.Time
+	interval time.Duration
+}
+
+// newSchedule returns a schedule for the given rate. A non-positive rate disables scheduling.
+func newSchedule(qps float64) *schedule {
+	if qps <= 0 {
+		return &schedule{}
+	}
+	return &schedule{
+		begin:    time.Now(),
+		interval: time.Duration(float64(time.Second) / qps),
+	}
+}
+
+// wait blocks until the intended send time of the sample, then returns the time that latency
+// should be measured from. A sample is considered missed if no worker was free to send it before
+// the following sample was already due.
+func (s *schedule) wait(sample int64) (time.Time, bool) {
+	if s.interval == 0 {
+		return time.Now(), false
+	}
+	intended := s.begin.Add(time.Duration(sample) * s.interval)
+	lag := time.Since(intended)
+	if lag < 0 {
+		time.Sleep(-lag)
+		return intended, false
+	}
+	return intended, lag >= s.interval
+}
@@ -0,0 +1,315 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/r7wang/gcloud-test/timer"
+)
+
+// testRunner returns a runner with the default configuration and the given concurrency.
+func testRunner(concurrency int) *runner {
+	config := DefaultConfig()
+	config.Concurrency = concurrency
+	config.Seed = 1
+	return newRunner(context.Background(), timer.NewMetrics(), config)
+}
+
+func TestRunWorkersInParallel(t *testing.T) {
+	const concurrency = 4
+	r := testRunner(concurrency)
+
+	// Every sample waits for the others to start, which only happens if each one runs on its own
+	// worker.
+	arrived := make(chan struct{}, concurrency)
+	release := make(chan struct{})
+	go func() {
+		for i := 0; i < concurrency; i++ {
+			<-arrived
+		}
+		close(release)
+	}()
+	err := r.run(budget{samples: concurrency}, "Test.parallel", func(ctx context.Context, rnd *rand.Rand, sample int, start time.Time) error {
+		arrived <- struct{}{}
+		select {
+		case <-release:
+			return nil
+		case <-time.After(5 * time.Second):
+			return fmt.Errorf("sample %d did not run alongside %d other workers", sample, concurrency-1)
+		}
+	})
+	if err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+}
+
+func TestRunSampleBudget(t *testing.T) {
+	const numSamples = 100
+	r := testRunner(4)
+	var counts [numSamples]int32
+	err := r.run(budget{samples: numSamples}, "Test.samples", func(ctx context.Context, rnd *rand.Rand, sample int, start time.Time) error {
+		atomic.AddInt32(&counts[sample], 1)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+	for sample, count := range counts {
+		if count != 1 {
+			t.Errorf("run ran sample %d %d times, want 1", sample, count)
+		}
+	}
+}
+
+func TestRunDurationBudget(t *testing.T) {
+	const duration = 50 * time.Millisecond
+	r := testRunner(4)
+	var ran int64
+	begin := time.Now()
+	err := r.run(budget{samples: -1, deadline: begin.Add(duration)}, "Test.duration", func(ctx context.Context, rnd *rand.Rand, sample int, start time.Time) error {
+		atomic.AddInt64(&ran, 1)
+		time.Sleep(time.Millisecond)
+		return nil
+	})
+	elapsed := time.Since(begin)
+	if err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+	if ran == 0 {
+		t.Errorf("run did not run any sample within %v", duration)
+	}
+	// Every worker finishes its last sample after the deadline, which takes about a millisecond.
+	if elapsed < duration || elapsed > duration+time.Second {
+		t.Errorf("run took %v, want about %v", elapsed, duration)
+	}
+}
+
+func TestRunErrorBudget(t *testing.T) {
+	const numSamples = 10
+	errSample := errors.New("sample failed")
+	tests := []struct {
+		errorBudget int
+		wantRan     int64
+		wantErr     string
+	}{
+		{errorBudget: 0, wantRan: 1, wantErr: errSample.Error()},
+		{errorBudget: 2, wantRan: 3, wantErr: "Test.errors exceeded its budget of 2 errors: sample failed"},
+		{errorBudget: -1, wantRan: numSamples},
+	}
+	for _, tt := range tests {
+		// A single worker makes the number of samples run before the test stops deterministic.
+		r := testRunner(1)
+		r.config.ErrorBudget = tt.errorBudget
+		var ran int64
+		err := r.run(budget{samples: numSamples}, "Test.errors", func(ctx context.Context, rnd *rand.Rand, sample int, start time.Time) error {
+			atomic.AddInt64(&ran, 1)
+			return errSample
+		})
+		if ran != tt.wantRan {
+			t.Errorf("run with an error budget of %d ran %d samples, want %d", tt.errorBudget, ran, tt.wantRan)
+		}
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("run with an error budget of %d returned error: %v", tt.errorBudget, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("run with an error budget of %d returned %v, want %q", tt.errorBudget, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	const numSamples = 3
+	r := testRunner(1)
+	r.config.Timeout = 10 * time.Millisecond
+	r.config.ErrorBudget = -1
+	err := r.run(budget{samples: numSamples}, "Test.timeout", func(ctx context.Context, rnd *rand.Rand, sample int, start time.Time) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+	summary, err := r.metrics.Summarize()
+	if err != nil {
+		t.Fatalf("Summarize returned error: %v", err)
+	}
+	// Samples that run past their own deadline are errors, not latency samples.
+	want := "Test.timeout: successes=0, errors=3, errorRate=100.00% (Timeout=3)"
+	if !strings.Contains(summary, want) {
+		t.Errorf("Summarize() = %q, want it to contain %q", summary, want)
+	}
+}
+
+func TestBudgetAllows(t *testing.T) {
+	tests := []struct {
+		name   string
+		b      budget
+		sample int64
+		want   bool
+	}{
+		{name: "within samples", b: budget{samples: 2}, sample: 1, want: true},
+		{name: "past samples", b: budget{samples: 2}, sample: 2, want: false},
+		{name: "before deadline", b: budget{samples: -1, deadline: time.Now().Add(time.Hour)}, sample: 1000, want: true},
+		{name: "past deadline", b: budget{samples: -1, deadline: time.Now().Add(-time.Second)}, sample: 0, want: false},
+	}
+	for _, tt := range tests {
+		if got := tt.b.allows(tt.sample); got != tt.want {
+			t.Errorf("%s: allows(%d) = %v, want %v", tt.name, tt.sample, got, tt.want)
+		}
+	}
+}
+
+func TestScheduleClosedLoop(t *testing.T) {
+	sched := newSchedule(0)
+	before := time.Now()
+	start, missed := sched.wait(100)
+	if missed {
+		t.Errorf("wait(100) missed a sample in closed-loop mode")
+	}
+	if start.Before(before) || time.Since(start) > time.Second {
+		t.Errorf("wait(100) = %v, want the current time", start)
+	}
+}
+
+func TestScheduleOpenLoop(t *testing.T) {
+	const interval = 10 * time.Millisecond
+	begin := time.Now().Add(-5 * interval)
+	sched := &schedule{begin: begin, interval: interval}
+
+	// A sample that is already late is measured from its intended send time, not from now, and
+	// is missed once the following sample is due.
+	start, missed := sched.wait(0)
+	if !start.Equal(begin) {
+		t.Errorf("wait(0) = %v, want the intended send time %v", start, begin)
+	}
+	if !missed {
+		t.Errorf("wait(0) did not miss a sample that is %v late", time.Since(begin))
+	}
+
+	// A sample that is not due yet waits for its intended send time.
+	intended := begin.Add(10 * interval)
+	start, missed = sched.wait(10)
+	if !start.Equal(intended) {
+		t.Errorf("wait(10) = %v, want the intended send time %v", start, intended)
+	}
+	if missed {
+		t.Errorf("wait(10) missed a sample that was not due yet")
+	}
+	if time.Now().Before(intended) {
+		t.Errorf("wait(10) returned before the intended send time %v", intended)
+	}
+}
+
+// stepRunner returns a runner that ramps the offered load from 100qps, holding each step for the
+// given time. It runs on a single worker, so that samples beyond its capacity queue up.
+func stepRunner(increment float64, duration time.Duration) *runner {
+	r := testRunner(1)
+	r.config.StepQPS = 100
+	r.config.StepIncrementQPS = increment
+	r.config.StepDuration = duration
+	return r
+}
+
+// recordedCurve returns the offered load of every step recorded for metricName, in order.
+func recordedCurve(t *testing.T, metrics *timer.Metrics, metricName string) []float64 {
+	t.Helper()
+	summary, err := metrics.Summarize()
+	if err != nil {
+		t.Fatalf("Summarize returned error: %v", err)
+	}
+	curve := []float64{}
+	inCurve := false
+	for _, line := range strings.Split(summary, "\n") {
+		if line == metricName+" [CURVE]:" {
+			inCurve = true
+			continue
+		}
+		if !inCurve || !strings.HasPrefix(line, "\toffered=") {
+			inCurve = false
+			continue
+		}
+		var offered float64
+		if _, err := fmt.Sscanf(line, "\toffered=%fqps", &offered); err != nil {
+			t.Fatalf("Cannot parse curve step %q: %v", line, err)
+		}
+		curve = append(curve, offered)
+	}
+	return curve
+}
+
+func TestRunStepsMaxQPS(t *testing.T) {
+	r := stepRunner(100, 50*time.Millisecond)
+	r.config.StepMaxQPS = 300
+	err := r.runSteps("Test.steps", func(ctx context.Context, rnd *rand.Rand, sample int, start time.Time) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("runSteps returned error: %v", err)
+	}
+	want := []float64{100, 200, 300}
+	if got := recordedCurve(t, r.metrics, "Test.steps"); !reflect.DeepEqual(got, want) {
+		t.Errorf("runSteps recorded steps at %v qps, want %v", got, want)
+	}
+}
+
+func TestRunStepsSLO(t *testing.T) {
+	// A sample takes 4ms, so a single worker keeps up with 100qps, while at 1000qps every sample
+	// queues behind the ones before it, and latency grows until the step ends.
+	r := stepRunner(900, 200*time.Millisecond)
+	r.config.StepSLO = 50 * time.Millisecond
+	r.config.StepMaxQPS = 5000
+	err := r.runSteps("Test.steps", func(ctx context.Context, rnd *rand.Rand, sample int, start time.Time) error {
+		time.Sleep(4 * time.Millisecond)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("runSteps returned error: %v", err)
+	}
+	want := []float64{100, 1000}
+	if got := recordedCurve(t, r.metrics, "Test.steps"); !reflect.DeepEqual(got, want) {
+		t.Errorf("runSteps recorded steps at %v qps, want %v", got, want)
+	}
+}
+
+func TestRunStepsErrorRate(t *testing.T) {
+	r := stepRunner(100, 50*time.Millisecond)
+	r.config.StepMaxQPS = 500
+	// Every step restarts its sample indexes, so the first sample of each step marks a new step.
+	var steps int32
+	err := r.runSteps("Test.steps", func(ctx context.Context, rnd *rand.Rand, sample int, start time.Time) error {
+		if sample == 0 {
+			atomic.AddInt32(&steps, 1)
+		}
+		if atomic.LoadInt32(&steps) >= 2 {
+			return errors.New("sample failed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("runSteps returned error: %v", err)
+	}
+	want := []float64{100, 200}
+	if got := recordedCurve(t, r.metrics, "Test.steps"); !reflect.DeepEqual(got, want) {
+		t.Errorf("runSteps recorded steps at %v qps, want %v", got, want)
+	}
+}
+
+func TestRunStepsUnbounded(t *testing.T) {
+	r := stepRunner(100, 50*time.Millisecond)
+	err := r.runSteps("Test.steps", func(ctx context.Context, rnd *rand.Rand, sample int, start time.Time) error {
+		return nil
+	})
+	if err == nil {
+		t.Errorf("runSteps without an SLO or a maximum QPS succeeded, want an error")
+	}
+}
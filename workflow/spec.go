@@ -0,0 +1,172 @@
+package workflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/r7wang/gcloud-test/datagen"
+)
+
+// Spec declares an experiment as an ordered list of test workflows, each with its own parameters,
+// so that experiments can be versioned alongside their results.
+//
+// An example spec is shown below.
+//	{
+//		"name": "read-heavy",
+//		"defaults": {"samples": 1000, "concurrency": 4, "keys": "zipfian"},
+//		"steps": [
+//			{"test": "OLTP.simpleRandomReadRow"},
+//			{"test": "OLTP.multiRandomRead", "numReads": 20},
+//			{"test": "OLTP.mix", "mix": {"read": 95, "update": 5}, "duration": "5m"}
+//		]
+//	}
+type Spec struct {
+	// Name identifies the experiment in the output.
+	Name string `json:"name"`
+	// Defaults apply to every step, unless the step overrides them.
+	Defaults StepSpec `json:"defaults"`
+	// Steps are run in order.
+	Steps []StepSpec `json:"steps"`
+}
+
+// StepSpec declares a single test workflow and its parameters. Keys takes a key distribution in the
+// format accepted by datagen.ParseKeyChooser. Unset parameters fall back to the
+// spec defaults, then to the command line configuration.
+type StepSpec struct {
+	// Test is the metric name of the test workflow, such as OLTP.simpleRandomReadRow.
+	Test        string  `json:"test"`
+	Samples     int     `json:"samples"`
+	Duration    string  `json:"duration"`
+	Concurrency int     `json:"concurrency"`
+	QPS         float64 `json:"qps"`
+	NumReads    int     `json:"numReads"`
+	Keys        string  `json:"keys"`
+	Timeout     string  `json:"timeout"`
+	Mix         Mix     `json:"mix"`
+}
+
+// LoadSpec reads a JSON encoded spec from the given path.
+func LoadSpec(path string) (*Spec, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	spec := &Spec{}
+	decoder := json.NewDecoder(f)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(spec); err != nil {
+		return nil, fmt.Errorf("Invalid workload spec %s: %v", path, err)
+	}
+	return spec, nil
+}
+
+// Run executes each step of the spec against the suite that provides its test workflow.
+func (s *Spec) Run(config Config, suites ...Suite) error {
+	base, err := s.Defaults.apply(config)
+	if err != nil {
+		return err
+	}
+	for _, step := range s.Steps {
+		if !config.Selected(step.Test) {
+			continue
+		}
+		suite := findSuite(step.Test, suites)
+		if suite == nil {
+			return fmt.Errorf("Unknown test %s", step.Test)
+		}
+		stepConfig, err := step.apply(base)
+		if err != nil {
+			return err
+		}
+		if err := suite.RunTest(step.Test, stepConfig); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SelectedTests returns the test of every step that passes the filters in config, in order.
+func (s *Spec) SelectedTests(config Config) []string {
+	names := []string{}
+	for _, step := range s.Steps {
+		if config.Selected(step.Test) {
+			names = append(names, step.Test)
+		}
+	}
+	return names
+}
+
+// apply returns a copy of config with every parameter set by the step overridden.
+func (s StepSpec) apply(config Config) (Config, error) {
+	if s.Samples > 0 {
+		config.NumSamples = s.Samples
+		config.Duration = 0
+	}
+	if s.Duration != "" {
+		duration, err := time.ParseDuration(s.Duration)
+		if err != nil {
+			return config, fmt.Errorf("Invalid duration for %s: %v", s.Test, err)
+		}
+		config.Duration = duration
+	}
+	if s.Timeout != "" {
+		timeout, err := time.ParseDuration(s.Timeout)
+		if err != nil {
+			return config, fmt.Errorf("Invalid timeout for %s: %v", s.Test, err)
+		}
+		config.Timeout = timeout
+	}
+	if s.Concurrency > 0 {
+		config.Concurrency = s.Concurrency
+	}
+	if s.QPS > 0 {
+		config.TargetQPS = s.QPS
+	}
+	if s.NumReads > 0 {
+		config.NumReads = s.NumReads
+	}
+	if s.Keys != "" {
+		keys, err := datagen.ParseKeyChooser(s.Keys, datagen.TransactionCount)
+		if err != nil {
+			return config, err
+		}
+		config.Keys = keys
+	}
+	if len(s.Mix) > 0 {
+		config.Mix = s.Mix
+	}
+	return config, nil
+}
+
+// mixSuite is a Suite that can also run a mixed workload.
+type mixSuite interface {
+	RunMix() error
+	// isolatedTests returns the name of every isolated test workflow, which Tests leaves out when
+	// a mix is configured.
+	isolatedTests() []string
+}
+
+// findSuite returns the suite that provides the named test workflow. The mixed workload and the
+// isolated tests are provided by any suite that can run one, whether or not a mix is configured
+// on the command line, since the step itself decides what it runs.
+func findSuite(name string, suites []Suite) Suite {
+	for _, suite := range suites {
+		names := suite.Tests()
+		if mix, ok := suite.(mixSuite); ok {
+			if name == mixMetricName {
+				return suite
+			}
+			names = mix.isolatedTests()
+		}
+		for _, test := range names {
+			if test == name {
+				return suite
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package workflow
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/r7wang/gcloud-test/timer"
+)
+
+// writeSpec writes a spec to a temporary file and returns its path.
+func writeSpec(t *testing.T, contents string) string {
+	f, err := ioutil.TempFile("", "spec-*.json")
+	if err != nil {
+		t.Fatalf("TempFile returned error: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(contents); err != nil {
+		t.Fatalf("WriteString returned error: %v", err)
+	}
+	return f.Name()
+}
+
+func TestLoadSpec(t *testing.T) {
+	path := writeSpec(t, `{
+		"name": "read-heavy",
+		"defaults": {"samples": 1000, "concurrency": 4, "keys": "zipfian"},
+		"steps": [
+			{"test": "OLTP.simpleRandomReadRow"},
+			{"test": "OLTP.mix", "mix": {"read": 95, "update": 5}, "duration": "5m", "timeout": "500ms"}
+		]
+	}`)
+	defer os.Remove(path)
+
+	spec, err := LoadSpec(path)
+	if err != nil {
+		t.Fatalf("LoadSpec returned error: %v", err)
+	}
+	want := &Spec{
+		Name:     "read-heavy",
+		Defaults: StepSpec{Samples: 1000, Concurrency: 4, Keys: "zipfian"},
+		Steps: []StepSpec{
+			{Test: "OLTP.simpleRandomReadRow"},
+			{Test: mixMetricName, Mix: Mix{ycsbRead: 95, ycsbUpdate: 5}, Duration: "5m", Timeout: "500ms"},
+		},
+	}
+	if !reflect.DeepEqual(spec, want) {
+		t.Errorf("LoadSpec = %+v, want %+v", spec, want)
+	}
+}
+
+func TestLoadSpecInvalid(t *testing.T) {
+	tests := map[string]string{
+		"unknown field": `{"name": "x", "steps": [{"test": "OLTP.simpleRandomReadRow", "sample": 10}]}`,
+		"wrong type":    `{"name": "x", "steps": [{"test": "OLTP.simpleRandomReadRow", "samples": "10"}]}`,
+		"malformed":     `{"name": "x", "steps": [`,
+	}
+	for name, contents := range tests {
+		path := writeSpec(t, contents)
+		if spec, err := LoadSpec(path); err == nil {
+			t.Errorf("%s: LoadSpec = %+v, want an error", name, spec)
+		}
+		os.Remove(path)
+	}
+}
+
+func TestLoadSpecWorkloads(t *testing.T) {
+	paths, err := filepath.Glob("../workloads/*.json")
+	if err != nil {
+		t.Fatalf("Glob returned error: %v", err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("No workloads found")
+	}
+	for _, path := range paths {
+		spec, err := LoadSpec(path)
+		if err != nil {
+			t.Errorf("LoadSpec(%s) returned error: %v", path, err)
+			continue
+		}
+		base, err := spec.Defaults.apply(DefaultConfig())
+		if err != nil {
+			t.Errorf("%s: invalid defaults: %v", path, err)
+			continue
+		}
+		for _, step := range spec.Steps {
+			if _, err := step.apply(base); err != nil {
+				t.Errorf("%s: invalid step %s: %v", path, step.Test, err)
+			}
+		}
+	}
+}
+
+func TestStepSpecApply(t *testing.T) {
+	config := DefaultConfig()
+	config.Duration = time.Minute
+
+	step := StepSpec{Test: "OLTP.simpleRandomReadRow", Samples: 10, Concurrency: 3, QPS: 50, NumReads: 7, Keys: "hotspot", Timeout: "1s"}
+	got, err := step.apply(config)
+	if err != nil {
+		t.Fatalf("apply returned error: %v", err)
+	}
+	if got.NumSamples != 10 || got.Duration != 0 {
+		t.Errorf("apply set samples=%d, duration=%v, want samples=10 to replace the duration", got.NumSamples, got.Duration)
+	}
+	if got.Concurrency != 3 || got.TargetQPS != 50 || got.NumReads != 7 || got.Timeout != time.Second {
+		t.Errorf("apply = %+v, want concurrency=3, qps=50, numReads=7, timeout=1s", got)
+	}
+	if got.Keys.String() != "hotspot:0.9:0.1" {
+		t.Errorf("apply set keys %s, want hotspot:0.9:0.1", got.Keys)
+	}
+
+	// Unset parameters keep the configuration that the step is applied to.
+	unchanged, err := StepSpec{Test: "OLTP.simpleRandomReadRow"}.apply(config)
+	if err != nil {
+		t.Fatalf("apply returned error: %v", err)
+	}
+	if unchanged.Duration != time.Minute || unchanged.Concurrency != config.Concurrency || unchanged.Keys != config.Keys {
+		t.Errorf("apply of an empty step changed the configuration to %+v", unchanged)
+	}
+
+	for _, invalid := range []StepSpec{
+		{Test: "OLTP.simpleRandomReadRow", Duration: "5"},
+		{Test: "OLTP.simpleRandomReadRow", Timeout: "fast"},
+		{Test: "OLTP.simpleRandomReadRow", Keys: "normal"},
+	} {
+		if _, err := invalid.apply(config); err == nil {
+			t.Errorf("apply(%+v) returned no error", invalid)
+		}
+	}
+}
+
+func TestFindSuite(t *testing.T) {
+	// With a mix on the command line, the OLTP suites only list the mixed workload, but a step can
+	// still run any of their isolated tests.
+	config := DefaultConfig()
+	config.Mix = Mix{"blindWrite": 1}
+	oltpSpanner := NewOLTPSpanner(context.Background(), nil, timer.NewMetrics(), config)
+	oltpBigtable := NewOLTPBigtable(context.Background(), nil, timer.NewMetrics(), config)
+	olap := &fakeSuite{names: []string{"OLAP.simpleRead"}}
+	tests := []struct {
+		name   string
+		suites []Suite
+		want   Suite
+	}{
+		{name: "OLTP.blindWrite", suites: []Suite{olap, oltpSpanner}, want: oltpSpanner},
+		{name: "OLTP.mix", suites: []Suite{olap, oltpSpanner}, want: oltpSpanner},
+		{name: "OLTP.simpleRandomReadRow", suites: []Suite{oltpBigtable}, want: oltpBigtable},
+		{name: "OLAP.simpleRead", suites: []Suite{oltpSpanner, olap}, want: olap},
+		{name: "OLTP.unknown", suites: []Suite{olap, oltpSpanner}, want: nil},
+	}
+	for _, tt := range tests {
+		if got := findSuite(tt.name, tt.suites); got != tt.want {
+			t.Errorf("findSuite(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
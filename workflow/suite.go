@@ -0,0 +1,164 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+
+	"github.com/r7wang/gcloud-test/timer"
+)
+
+// Suite is a set of named test workflows against a single database.
+type Suite interface {
+	// Run sequentially executes all of the test workflows.
+	Run() error
+	// Tests returns the name of every test workflow that can be passed to RunTest.
+	Tests() []string
+	// RunTest executes a single test workflow with the given configuration.
+	RunTest(name string, config Config) error
+}
+
+// suiteBase holds the state that every suite shares: the context that samples run with, the
+// runner, the metrics, and the configuration that the runner was built from.
+type suiteBase struct {
+	ctx     context.Context
+	runner  *runner
+	metrics *timer.Metrics
+	config  Config
+}
+
+// newSuiteBase returns a new suiteBase instance.
+func newSuiteBase(ctx context.Context, metrics *timer.Metrics, config Config) suiteBase {
+	return suiteBase{ctx: ctx, runner: newRunner(ctx, metrics, config), metrics: metrics, config: config}
+}
+
+// withConfig returns a copy that runs with the given configuration. Each suite copies itself
+// around it, so that a single test can run with its own configuration.
+func (b suiteBase) withConfig(config Config) suiteBase {
+	return newSuiteBase(b.ctx, b.metrics, config)
+}
+
+// test is a named test workflow. The name doubles as the metric name.
+type test struct {
+	name string
+	run  func() error
+}
+
+// testNames returns the name of each test.
+func testNames(tests []test) []string {
+	names := []string{}
+	for _, t := range tests {
+		names = append(names, t.name)
+	}
+	return names
+}
+
+// SelectedTests returns the name of every test across the suites that passes the filters in
+// config, in the order that they would be run.
+func SelectedTests(config Config, suites ...Suite) []string {
+	names := []string{}
+	for _, suite := range suites {
+		for _, name := range suite.Tests() {
+			if config.Selected(name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// runTests sequentially executes each test that passes the filters in config, stopping at the
+// first error.
+func runTests(tests []test, config Config) error {
+	for _, t := range tests {
+		if !config.Selected(t.name) {
+			continue
+		}
+		if err := t.run(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runNamedTest executes the test with the given name.
+func runNamedTest(tests []test, name string) error {
+	for _, t := range tests {
+		if t.name == name {
+			return t.run()
+		}
+	}
+	return fmt.Errorf("Unknown test %s", name)
+}
+
+// prepared returns a test that runs prepare before t. The time spent in prepare is not part of any
+// sample, so it is suited to loading the inputs of a test.
+func prepared(prepare func() error, t test) test {
+	return test{
+		name: t.name,
+		run: func() error {
+			if err := prepare(); err != nil {
+				return err
+			}
+			return t.run()
+		},
+	}
+}
+
+// keyStore carries keys written by one test over to a later test that consumes them.
+type keyStore struct {
+	mu   sync.Mutex
+	keys []int64
+}
+
+// take removes a random key from the store and returns it, so that no later sample picks the same
+// key again. It fails once every key has been taken.
+func (s *keyStore) take(r *rand.Rand) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.keys) == 0 {
+		return 0, fmt.Errorf("No keys left to take")
+	}
+	idx := r.Intn(len(s.keys))
+	key := s.keys[idx]
+	last := len(s.keys) - 1
+	s.keys[idx] = s.keys[last]
+	s.keys = s.keys[:last]
+	return key, nil
+}
+
+// test returns a test that runs testFunc through runTest.
+func (r *runner) test(testFunc func(ctx context.Context, r *rand.Rand) error, name string) test {
+	return test{
+		name: name,
+		run: func() error {
+			return r.runTest(testFunc, name)
+		},
+	}
+}
+
+// testReturns returns a test that runs testFunc through runTestReturns and saves the returned keys
+// to store.
+func (r *runner) testReturns(testFunc func(ctx context.Context, r *rand.Rand) (int64, error), store *keyStore, name string) test {
+	return test{
+		name: name,
+		run: func() error {
+			keys, err := r.runTestReturns(testFunc, name)
+			store.keys = keys
+			return err
+		},
+	}
+}
+
+// testWith returns a test that runs testFunc through runTestWith, consuming the keys in store.
+func (r *runner) testWith(testFunc func(ctx context.Context, r *rand.Rand, key int64) error, store *keyStore, name string) test {
+	return test{
+		name: name,
+		run: func() error {
+			keys := store.keys
+			store.keys = nil
+			return r.runTestWith(testFunc, keys, name)
+		},
+	}
+}
@@ -0,0 +1,60 @@
+package workflow
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+// fakeSuite is a suite that only lists its tests.
+type fakeSuite struct {
+	names []string
+}
+
+func (s fakeSuite) Run() error {
+	return nil
+}
+
+func (s fakeSuite) Tests() []string {
+	return s.names
+}
+
+func (s fakeSuite) RunTest(name string, config Config) error {
+	return nil
+}
+
+func TestSelectedTests(t *testing.T) {
+	config := DefaultConfig()
+	config.Run = regexp.MustCompile(`Read`)
+	config.Skip = regexp.MustCompile(`^OLAP\.`)
+	oltp := fakeSuite{names: []string{"OLTP.simpleRandomReadRow", "OLTP.blindWrite", "OLTP.multiRandomRead"}}
+	olap := fakeSuite{names: []string{"OLAP.simpleRead"}}
+
+	got := SelectedTests(config, oltp, olap)
+	want := []string{"OLTP.simpleRandomReadRow", "OLTP.multiRandomRead"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SelectedTests = %v, want %v", got, want)
+	}
+}
+
+func TestRunTestsSelected(t *testing.T) {
+	config := DefaultConfig()
+	config.Skip = regexp.MustCompile(`^OLTP\.delete$`)
+	ran := []string{}
+	tests := []test{}
+	for _, name := range []string{"OLTP.blindWrite", "OLTP.delete", "OLTP.atomicSwap"} {
+		name := name
+		tests = append(tests, test{name: name, run: func() error {
+			ran = append(ran, name)
+			return nil
+		}})
+	}
+
+	if err := runTests(tests, config); err != nil {
+		t.Fatalf("runTests returned error: %v", err)
+	}
+	want := []string{"OLTP.blindWrite", "OLTP.atomicSwap"}
+	if !reflect.DeepEqual(ran, want) {
+		t.Errorf("runTests ran %v, want %v", ran, want)
+	}
+}
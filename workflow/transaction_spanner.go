@@ -0,0 +1,115 @@
+package workflow
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/r7wang/gcloud-test/datagen"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// transactionKeyColumnsSpanner lists the columns that the primary key of a transaction is built
+// from with any schema variant, in the order that readTransactionKey expects.
+var transactionKeyColumnsSpanner = []string{"Id", datagen.TransactionFromUserColumn, "Time"}
+
+// isTransactionKeyColumn returns whether a column of the transactions table is part of its primary
+// key with the given schema variant.
+func isTransactionKeyColumn(schema datagen.SpannerSchema, column string) bool {
+	for _, keyColumn := range schema.TransactionKeyColumns {
+		if keyColumn == column {
+			return true
+		}
+	}
+	return false
+}
+
+// spannerReader reads rows within either a read-only or a read-write transaction.
+type spannerReader interface {
+	ReadWithOptions(ctx context.Context, table string, keys spanner.KeySet, columns []string, opts *spanner.ReadOptions) *spanner.RowIterator
+}
+
+// readTransactionsByID reads the transactions whose IDs are in keys, in ID order. When the ID is
+// not the primary key, the rows are read through the ID index, which stores every column.
+func readTransactionsByID(
+	ctx context.Context,
+	reader spannerReader,
+	schema datagen.SpannerSchema,
+	keys spanner.KeySet,
+	columns []string,
+	limit int,
+) *spanner.RowIterator {
+
+	return reader.ReadWithOptions(ctx, datagen.TransactionTableName, keys, columns, &spanner.ReadOptions{
+		Index: schema.TransactionIDIndex,
+		Limit: limit,
+	})
+}
+
+// readTransactionByID reads a single transaction by ID. It fails with a NotFound error if there is
+// no such transaction.
+func readTransactionByID(
+	ctx context.Context,
+	reader spannerReader,
+	schema datagen.SpannerSchema,
+	id int64,
+	columns []string,
+) (*spanner.Row, error) {
+
+	iter := readTransactionsByID(ctx, reader, schema, spanner.Key{id}, columns, 0)
+	defer iter.Stop()
+	row, err := iter.Next()
+	if err == iterator.Done {
+		return nil, status.Errorf(codes.NotFound, "transaction %d not found", id)
+	}
+	return row, err
+}
+
+// readTransactionKey returns the primary key of the transaction with the given ID.
+func readTransactionKey(
+	ctx context.Context,
+	reader spannerReader,
+	schema datagen.SpannerSchema,
+	id int64,
+) (spanner.Key, error) {
+
+	row, err := readTransactionByID(ctx, reader, schema, id, transactionKeyColumnsSpanner)
+	if err != nil {
+		return nil, err
+	}
+	var fromUserID int64
+	var ts time.Time
+	if err := row.Columns(&id, &fromUserID, &ts); err != nil {
+		return nil, err
+	}
+	return schema.TransactionKey(id, fromUserID, ts), nil
+}
+
+// deleteTransactionByID deletes a single transaction by ID. The row is deleted blindly with Apply
+// when the ID is the primary key. Otherwise, its primary key is looked up through the ID index
+// within the same ReadWriteTransaction that deletes it.
+func deleteTransactionByID(ctx context.Context, client *spanner.Client, schema datagen.SpannerSchema, id int64) error {
+	if schema.TransactionIDIndex == "" {
+		mutation := spanner.Delete(datagen.TransactionTableName, spanner.Key{id})
+		_, err := client.Apply(ctx, []*spanner.Mutation{mutation})
+		return err
+	}
+	_, err := client.ReadWriteTransaction(ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+		key, err := readTransactionKey(ctx, txn, schema, id)
+		if err != nil {
+			return err
+		}
+		return txn.BufferWrite([]*spanner.Mutation{spanner.Delete(datagen.TransactionTableName, key)})
+	})
+	return err
+}
+
+// transactionUpdate returns a mutation that changes the given columns of the transaction with the
+// given primary key. None of the columns can be part of the primary key.
+func transactionUpdate(schema datagen.SpannerSchema, key spanner.Key, columns []string, values []interface{}) *spanner.Mutation {
+	allColumns := append(append([]string{}, schema.TransactionKeyColumns...), columns...)
+	allValues := append(append([]interface{}{}, key...), values...)
+	return spanner.Update(datagen.TransactionTableName, allColumns, allValues)
+}
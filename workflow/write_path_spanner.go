@@ -0,0 +1,176 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/r7wang/gcloud-test/datagen"
+)
+
+// writePath is one of the ways that Spanner can write a single transaction. Each method commits
+// its change on its own. Rows are addressed by their full primary key, which insert returns, since
+// the transaction ID alone is only the key with some schema variants.
+type writePath interface {
+	// name is appended to the metric name of each test that writes through the path.
+	name() string
+	insert(ctx context.Context, client *spanner.Client, txn Transaction) (spanner.Key, error)
+	// update only changes the receiver, which is never part of the primary key.
+	update(ctx context.Context, client *spanner.Client, key spanner.Key, toUserID int64) error
+	delete(ctx context.Context, client *spanner.Client, key spanner.Key) error
+}
+
+// writePaths returns every write path for the given schema variant, in the order that they are
+// tested.
+//
+// See the links below for more information.
+//		https://cloud.google.com/spanner/docs/modify-mutation-api
+//		https://cloud.google.com/spanner/docs/dml-tasks
+func writePaths(schema datagen.SpannerSchema) []writePath {
+	return []writePath{applyPath{schema: schema}, bufferWritePath{schema: schema}, batchUpdatePath{schema: schema}}
+}
+
+// applyPath writes mutations with client.Apply, which runs its own read-write transaction.
+type applyPath struct {
+	schema datagen.SpannerSchema
+}
+
+func (p applyPath) name() string {
+	return "apply"
+}
+
+func (p applyPath) insert(ctx context.Context, client *spanner.Client, txn Transaction) (spanner.Key, error) {
+	ts, err := client.Apply(ctx, []*spanner.Mutation{insertMutation(txn)})
+	if err != nil {
+		return nil, err
+	}
+	return p.schema.TransactionKey(txn.ID, txn.FromUserID, ts), nil
+}
+
+func (p applyPath) update(ctx context.Context, client *spanner.Client, key spanner.Key, toUserID int64) error {
+	_, err := client.Apply(ctx, []*spanner.Mutation{updateMutation(p.schema, key, toUserID)})
+	return err
+}
+
+func (p applyPath) delete(ctx context.Context, client *spanner.Client, key spanner.Key) error {
+	_, err := client.Apply(ctx, []*spanner.Mutation{spanner.Delete(datagen.TransactionTableName, key)})
+	return err
+}
+
+// bufferWritePath buffers mutations with txn.BufferWrite within an explicit ReadWriteTransaction.
+type bufferWritePath struct {
+	schema datagen.SpannerSchema
+}
+
+func (p bufferWritePath) name() string {
+	return "bufferWrite"
+}
+
+func (p bufferWritePath) insert(ctx context.Context, client *spanner.Client, txn Transaction) (spanner.Key, error) {
+	ts, err := p.write(ctx, client, insertMutation(txn))
+	if err != nil {
+		return nil, err
+	}
+	return p.schema.TransactionKey(txn.ID, txn.FromUserID, ts), nil
+}
+
+func (p bufferWritePath) update(ctx context.Context, client *spanner.Client, key spanner.Key, toUserID int64) error {
+	_, err := p.write(ctx, client, updateMutation(p.schema, key, toUserID))
+	return err
+}
+
+func (p bufferWritePath) delete(ctx context.Context, client *spanner.Client, key spanner.Key) error {
+	_, err := p.write(ctx, client, spanner.Delete(datagen.TransactionTableName, key))
+	return err
+}
+
+func (p bufferWritePath) write(ctx context.Context, client *spanner.Client, mutation *spanner.Mutation) (time.Time, error) {
+	return client.ReadWriteTransaction(ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+		return txn.BufferWrite([]*spanner.Mutation{mutation})
+	})
+}
+
+// batchUpdatePath runs DML statements with txn.BatchUpdate within an explicit
+// ReadWriteTransaction. DML can only write the commit timestamp through PENDING_COMMIT_TIMESTAMP().
+type batchUpdatePath struct {
+	schema datagen.SpannerSchema
+}
+
+func (p batchUpdatePath) name() string {
+	return "batchUpdate"
+}
+
+func (p batchUpdatePath) insert(ctx context.Context, client *spanner.Client, txn Transaction) (spanner.Key, error) {
+	ts, err := p.write(ctx, client, spanner.Statement{
+		SQL: `INSERT INTO Transactions (Id, CompanyId, FromUserId, ToUserId, Time)
+				VALUES (@id, @companyId, @fromUserId, @toUserId, PENDING_COMMIT_TIMESTAMP())`,
+		Params: map[string]interface{}{
+			"id":         txn.ID,
+			"companyId":  txn.CompanyID,
+			"fromUserId": txn.FromUserID,
+			"toUserId":   txn.ToUserID,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p.schema.TransactionKey(txn.ID, txn.FromUserID, ts), nil
+}
+
+func (p batchUpdatePath) update(ctx context.Context, client *spanner.Client, key spanner.Key, toUserID int64) error {
+	where, params := p.keyCondition(key)
+	params["toUserId"] = toUserID
+	_, err := p.write(ctx, client, spanner.Statement{
+		SQL: fmt.Sprintf(`UPDATE Transactions t
+				SET t.ToUserId = @toUserId
+				WHERE %s`, where),
+		Params: params,
+	})
+	return err
+}
+
+func (p batchUpdatePath) delete(ctx context.Context, client *spanner.Client, key spanner.Key) error {
+	where, params := p.keyCondition(key)
+	_, err := p.write(ctx, client, spanner.Statement{
+		SQL: fmt.Sprintf(`DELETE FROM Transactions t
+				WHERE %s`, where),
+		Params: params,
+	})
+	return err
+}
+
+// keyCondition returns a condition that matches the row with the given primary key, along with
+// its parameters.
+func (p batchUpdatePath) keyCondition(key spanner.Key) (string, map[string]interface{}) {
+	conditions := []string{}
+	params := map[string]interface{}{}
+	for i, column := range p.schema.TransactionKeyColumns {
+		conditions = append(conditions, fmt.Sprintf("t.%s = @%s", column, column))
+		params[column] = key[i]
+	}
+	return strings.Join(conditions, " AND "), params
+}
+
+func (p batchUpdatePath) write(ctx context.Context, client *spanner.Client, stmt spanner.Statement) (time.Time, error) {
+	return client.ReadWriteTransaction(ctx, func(ctx context.Context, txn *spanner.ReadWriteTransaction) error {
+		_, err := txn.BatchUpdate(ctx, []spanner.Statement{stmt})
+		return err
+	})
+}
+
+func insertMutation(txn Transaction) *spanner.Mutation {
+	return spanner.InsertMap(datagen.TransactionTableName, map[string]interface{}{
+		"id":         txn.ID,
+		"companyId":  txn.CompanyID,
+		"fromUserId": txn.FromUserID,
+		"toUserId":   txn.ToUserID,
+		"time":       spanner.CommitTimestamp,
+	})
+}
+
+// updateMutation changes the receiver of the transaction with the given primary key.
+func updateMutation(schema datagen.SpannerSchema, key spanner.Key, toUserID int64) *spanner.Mutation {
+	return transactionUpdate(schema, key, []string{datagen.TransactionToUserColumn}, []interface{}{toUserID})
+}
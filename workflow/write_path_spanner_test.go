@@ -0,0 +1,48 @@
+package workflow
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/r7wang/gcloud-test/datagen"
+)
+
+func TestBatchUpdateKeyCondition(t *testing.T) {
+	ts := time.Unix(datagen.TransactionMinTime, 0)
+	tests := []struct {
+		schema     string
+		where      string
+		wantParams map[string]interface{}
+	}{
+		{
+			schema:     "key-id",
+			where:      "t.Id = @Id",
+			wantParams: map[string]interface{}{"Id": int64(1)},
+		},
+		{
+			schema:     "key-from-user-and-time",
+			where:      "t.FromUserId = @FromUserId AND t.Time = @Time",
+			wantParams: map[string]interface{}{"FromUserId": int64(2), "Time": ts},
+		},
+		{
+			schema:     "interleaved",
+			where:      "t.FromUserId = @FromUserId AND t.Time = @Time AND t.Id = @Id",
+			wantParams: map[string]interface{}{"FromUserId": int64(2), "Time": ts, "Id": int64(1)},
+		},
+	}
+	for _, tt := range tests {
+		schema, err := datagen.LookupSpannerSchema(tt.schema)
+		if err != nil {
+			t.Fatalf("LookupSpannerSchema(%q) returned error: %v", tt.schema, err)
+		}
+		path := batchUpdatePath{schema: schema}
+		where, params := path.keyCondition(schema.TransactionKey(1, 2, ts))
+		if where != tt.where {
+			t.Errorf("%s: keyCondition() = %q, want %q", tt.schema, where, tt.where)
+		}
+		if !reflect.DeepEqual(params, tt.wantParams) {
+			t.Errorf("%s: keyCondition() params = %v, want %v", tt.schema, params, tt.wantParams)
+		}
+	}
+}